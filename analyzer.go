@@ -0,0 +1,546 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Analyzer extracts structure from the source files of one language. The
+// focus and trimming logic only sees the language-neutral symbols and
+// references it reports, so languages can be added independently by
+// registering an Analyzer for them.
+type Analyzer interface {
+	// Summarize returns the AST summary of a file.
+	Summarize(src []byte, relPath string, cfg *Config) *ASTInfo
+	// Symbols lists the declarations of a file with the lines they span.
+	Symbols(src []byte, relPath string, cfg *Config) []Symbol
+	// References lists the names referenced, typically called, from inside
+	// the declarations of a file.
+	References(src []byte, relPath string, cfg *Config) []Reference
+	// Skeleton reduces a file to its declarations with bodies elided,
+	// keeping original line numbers. It returns errNoSkeleton if the
+	// language has no skeleton form.
+	Skeleton(src []byte) ([]sourceLine, error)
+}
+
+// projectLinker is implemented by analyzers that resolve references across
+// the files of a language, such as Go interface method calls.
+type projectLinker interface {
+	// Link returns, for each abstract symbol key (such as "Iface.Method"),
+	// the keys of its implementations, and any references that can only be
+	// resolved with the whole project in view.
+	Link(files []sourceFile, cfg *Config) (map[string][]string, []Reference)
+}
+
+// schemaAnalyzer is implemented by analyzers of interface definition
+// languages, such as protobuf, whose declarations are compiled into code of
+// other languages. Focusing on a schema symbol pulls in the code generated
+// from it, and the other way round.
+type schemaAnalyzer interface {
+	// Generated returns the names that code generated from sym declares,
+	// such as "UserServiceClient.GetUser" for the rpc "UserService.GetUser".
+	// They are matched with foldName, as each language applies its own
+	// naming conventions.
+	Generated(sym Symbol) []string
+}
+
+// Symbol is a declaration found by an analyzer. Key names it the way focus
+// symbols are written: "Name" or "Type.Method".
+type Symbol struct {
+	Key   string
+	Kind  string // "func", "method", "type", "class", ...
+	Start int    // first line, 1-based
+	End   int    // last line, inclusive
+}
+
+// Reference is a use of a name from inside the symbol From. To is the
+// name as written at the use site and is matched against symbol keys
+// with matchesSymbol.
+type Reference struct {
+	From string
+	To   string
+	// Path is the file declaring From. References lists the references of
+	// one file and leaves it empty; Link sets it.
+	Path string
+}
+
+// sourceFile is a project file handed to a projectLinker.
+type sourceFile struct {
+	Path string
+	Src  []byte
+}
+
+// errNoSkeleton is returned by Analyzer.Skeleton for languages without a
+// skeleton form; such files are kept in full.
+var errNoSkeleton = errors.New("skeleton not supported")
+
+// analyzers maps a language, as found in languageMap, to its Analyzer.
+var analyzers = map[string]Analyzer{}
+
+// registerAnalyzer registers a as the Analyzer for languages.
+func registerAnalyzer(a Analyzer, languages ...string) {
+	for _, lang := range languages {
+		analyzers[lang] = a
+	}
+}
+
+// analyzerFor returns the Analyzer registered for language, or nil.
+func analyzerFor(language string) Analyzer {
+	return analyzers[language]
+}
+
+// defSymbols converts the spans found by a script analyzer into symbols.
+func defSymbols(defs []defSpan) []Symbol {
+	syms := make([]Symbol, len(defs))
+	for i, d := range defs {
+		syms[i] = Symbol{Key: d.Key, Kind: d.Kind, Start: d.Start, End: d.End}
+	}
+	return syms
+}
+
+// defReferences lists the calls made from the spans found by a script analyzer.
+func defReferences(defs []defSpan) []Reference {
+	var refs []Reference
+	for _, d := range defs {
+		for _, c := range d.Calls {
+			refs = append(refs, Reference{From: d.Key, To: c})
+		}
+	}
+	return refs
+}
+
+// symbolNode is a symbol declared in one file. Declarations of one key in
+// different files, such as the main functions of two programs, are
+// distinct nodes.
+type symbolNode struct {
+	Key  string
+	Path string
+}
+
+// symbolTable indexes the symbols of all analyzed files and resolves the
+// names of focus symbols and references. References resolve within the
+// language of the symbol they are made from; only schema links cross
+// languages.
+type symbolTable struct {
+	// nodes maps each symbol key to its declarations.
+	nodes map[string][]symbolNode
+	// bases maps the last component of each symbol key to the keys, so
+	// that names are only matched against keys they can match.
+	bases map[string][]string
+	// langs maps each analyzed file to its language.
+	langs map[string]string
+	// impls maps each abstract symbol key, such as "Iface.Method", to its
+	// implementations.
+	impls map[string][]symbolNode
+	// generated maps each schema symbol to the folded names of the code
+	// generated from it.
+	generated map[symbolNode][]string
+	// links connects each schema symbol to the symbols generated from it
+	// in other languages, and those back to it.
+	links map[symbolNode]map[symbolNode]struct{}
+}
+
+// add records the declaration of s in the file at path.
+func (t *symbolTable) add(s Symbol, path string) symbolNode {
+	n := symbolNode{Key: s.Key, Path: path}
+	if t.declared(n) {
+		return n
+	}
+	if len(t.nodes[s.Key]) == 0 {
+		base := symbolBase(s.Key)
+		t.bases[base] = append(t.bases[base], s.Key)
+	}
+	t.nodes[s.Key] = append(t.nodes[s.Key], n)
+	return n
+}
+
+// abstract reports whether name matches an abstract symbol with known
+// implementations.
+func (t *symbolTable) abstract(name string) bool {
+	for ik := range t.impls {
+		if matchesSymbol(ik, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// declared reports whether n is a symbol of the table.
+func (t *symbolTable) declared(n symbolNode) bool {
+	return slices.Contains(t.nodes[n.Key], n)
+}
+
+// symbolBase returns the last component of a symbol key or name, which is
+// all that matchesSymbol compares of an unqualified name.
+func symbolBase(key string) string {
+	return strings.TrimPrefix(key[strings.LastIndex(key, ".")+1:], "*")
+}
+
+// foldName folds case and underscores out of a symbol name, so that the
+// spellings different languages give to one schema name, such as GetUser,
+// getUser and get_user, compare equal.
+func foldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// foldedMatch reports whether the folded key is the folded name or ends
+// with it as a qualified name, as matchesSymbol does for unfolded names.
+func foldedMatch(key, name string) bool {
+	return key == name || strings.HasSuffix(key, "."+name)
+}
+
+// link connects the schema symbols to the symbols of other languages that
+// are named after them. Abstract symbols stand for their implementations,
+// so that a Go server implementing a generated interface is linked too.
+func (t *symbolTable) link() {
+	if len(t.generated) == 0 {
+		return
+	}
+	folded := map[string][]symbolNode{}
+	for k, nodes := range t.nodes {
+		for _, n := range nodes {
+			if _, schema := t.generated[n]; !schema {
+				folded[foldName(k)] = append(folded[foldName(k)], n)
+			}
+		}
+	}
+	for ik, implNodes := range t.impls {
+		folded[foldName(ik)] = append(folded[foldName(ik)], implNodes...)
+	}
+	add := func(from, to symbolNode) {
+		if t.links[from] == nil {
+			t.links[from] = map[symbolNode]struct{}{}
+		}
+		t.links[from][to] = struct{}{}
+	}
+	for s, names := range t.generated {
+		for fk, nodes := range folded {
+			for _, g := range names {
+				if !foldedMatch(fk, g) {
+					continue
+				}
+				for _, n := range nodes {
+					add(s, n)
+					add(n, s)
+				}
+				break
+			}
+		}
+	}
+}
+
+// resolve returns the symbols name refers to when used from inside the
+// symbol from: the symbols it matches directly, the implementations of the
+// abstract symbols it matches, and the symbols linked to either through a
+// schema. Schema symbols whose generated code would declare name are
+// linked to it even if that code is not part of the project.
+//
+// Direct matches are looked for in the language of from, in its file, else
+// in its directory, else anywhere; implementations in its language. A
+// zero from, as for the focus symbol, matches in every file.
+func (t *symbolTable) resolve(name string, from symbolNode) (direct, impls, linked []symbolNode) {
+	var file, dir, rest []symbolNode
+	lang := t.langs[from.Path]
+	for _, k := range t.bases[symbolBase(name)] {
+		if !matchesSymbol(k, name) {
+			continue
+		}
+		for _, n := range t.nodes[k] {
+			switch {
+			case from.Path == "":
+				direct = append(direct, n)
+			case t.langs[n.Path] != lang:
+			case n.Path == from.Path:
+				file = append(file, n)
+			case filepath.Dir(n.Path) == filepath.Dir(from.Path):
+				dir = append(dir, n)
+			default:
+				rest = append(rest, n)
+			}
+		}
+	}
+	switch {
+	case from.Path == "":
+	case len(file) > 0:
+		direct = file
+	case len(dir) > 0:
+		direct = dir
+	default:
+		direct = rest
+	}
+	// A call qualified by a package or receiver variable, as in
+	// "store.NewPG" or "s.Save", names the symbol by its last component.
+	if base := symbolBase(name); from.Path != "" && len(direct) == 0 && base != name && !t.abstract(name) {
+		return t.resolve(base, from)
+	}
+	for ik, implNodes := range t.impls {
+		if !matchesSymbol(ik, name) {
+			continue
+		}
+		for _, n := range implNodes {
+			if from.Path == "" || t.langs[n.Path] == lang {
+				impls = append(impls, n)
+			}
+		}
+	}
+	if len(t.generated) == 0 {
+		return direct, impls, nil
+	}
+
+	seen := map[symbolNode]struct{}{}
+	for _, n := range direct {
+		seen[n] = struct{}{}
+	}
+	add := func(n symbolNode) {
+		if _, ok := seen[n]; !ok {
+			seen[n] = struct{}{}
+			linked = append(linked, n)
+		}
+	}
+	// addLinks adds the symbols linked to n and, for a symbol generated
+	// from a schema, the other symbols generated from it.
+	addLinks := func(n symbolNode) {
+		for l := range t.links[n] {
+			add(l)
+			if _, schema := t.generated[l]; schema {
+				for m := range t.links[l] {
+					add(m)
+				}
+			}
+		}
+	}
+	for _, n := range direct {
+		addLinks(n)
+	}
+	for _, n := range impls {
+		addLinks(n)
+	}
+	folded := foldName(name)
+	for s, names := range t.generated {
+		for _, g := range names {
+			if foldedMatch(g, folded) {
+				add(s)
+				addLinks(s)
+				break
+			}
+		}
+	}
+	return direct, impls, linked
+}
+
+// symbolAnalysis is the outcome of analyzeSymbols.
+type symbolAnalysis struct {
+	// Calls are the call edges of the focused subgraph.
+	Calls [][2]symbolNode
+	// Roots are the symbols the focus symbol resolved to.
+	Roots []symbolNode
+}
+
+// callEdges returns the sorted call edges between the symbols of the files
+// in kept, by key. Keys declared in more than one file of the graph are
+// labelled with the file, so the edges of one do not appear to belong to
+// another.
+func (sa *symbolAnalysis) callEdges(kept map[string]bool) []callEdge {
+	calls := slices.DeleteFunc(slices.Clone(sa.Calls), func(e [2]symbolNode) bool {
+		return !kept[e[0].Path] || !kept[e[1].Path]
+	})
+	paths := map[string]map[string]bool{}
+	for _, e := range calls {
+		for _, n := range e {
+			if paths[n.Key] == nil {
+				paths[n.Key] = map[string]bool{}
+			}
+			paths[n.Key][n.Path] = true
+		}
+	}
+	label := func(n symbolNode) string {
+		if len(paths[n.Key]) > 1 {
+			return n.Key + " (" + filepath.ToSlash(n.Path) + ")"
+		}
+		return n.Key
+	}
+	seen := map[callEdge]bool{}
+	var edges []callEdge
+	for _, e := range calls {
+		ce := callEdge{From: label(e[0]), To: label(e[1])}
+		if !seen[ce] {
+			seen[ce] = true
+			edges = append(edges, ce)
+		}
+	}
+	sortEdges(edges)
+	return edges
+}
+
+// analyzeSymbols builds a symbol table for all files with a registered
+// analyzer and marks files according to the configured focus symbol and
+// depth. Names are resolved across languages only through schemas, so
+// that focusing on a protobuf message also reaches the code generated from
+// it. Marking influences which files are kept when trimming to token
+// limits.
+func analyzeSymbols(ctx *Context, cfg *Config) *symbolAnalysis {
+	table := &symbolTable{
+		nodes:     map[string][]symbolNode{},
+		bases:     map[string][]string{},
+		langs:     map[string]string{},
+		impls:     map[string][]symbolNode{},
+		generated: map[symbolNode][]string{},
+		links:     map[symbolNode]map[symbolNode]struct{}{},
+	}
+	// refs maps a symbol to the names referenced from it.
+	refs := map[symbolNode]map[string]struct{}{}
+	addRef := func(r Reference) {
+		from := symbolNode{Key: r.From, Path: r.Path}
+		if refs[from] == nil {
+			refs[from] = map[string]struct{}{}
+		}
+		refs[from][r.To] = struct{}{}
+	}
+	byLang := map[string][]sourceFile{}
+	fileIndex := map[string]int{}
+
+	for i := range ctx.Files {
+		f := &ctx.Files[i]
+		fileIndex[f.Path] = i
+		a := analyzerFor(f.Language)
+		if a == nil {
+			continue
+		}
+		src, err := analyzedSource(filepath.Join(ctx.ProjectPath, f.Path), cfg)
+		if err != nil {
+			continue
+		}
+		table.langs[f.Path] = f.Language
+		schema, _ := a.(schemaAnalyzer)
+		for _, s := range a.Symbols(src, f.Path, cfg) {
+			n := table.add(s, f.Path)
+			if schema != nil {
+				for _, g := range schema.Generated(s) {
+					table.generated[n] = append(table.generated[n], foldName(g))
+				}
+			}
+		}
+		for _, r := range a.References(src, f.Path, cfg) {
+			r.Path = f.Path
+			addRef(r)
+		}
+		if _, ok := a.(projectLinker); ok && cfg.Focus != "" {
+			byLang[f.Language] = append(byLang[f.Language], sourceFile{Path: f.Path, Src: src})
+		}
+	}
+
+	// Linkers resolve abstract symbols, such as interface methods, to their
+	// implementations, both for focus symbols and for references made
+	// through them. They only serve focus tracing, so files are handed to
+	// them only when a focus symbol is set.
+	langs := make([]string, 0, len(byLang))
+	for lang := range byLang {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		linked, linkedRefs := analyzerFor(lang).(projectLinker).Link(byLang[lang], cfg)
+		for k, implKeys := range linked {
+			for _, ik := range implKeys {
+				for _, n := range table.nodes[ik] {
+					if table.langs[n.Path] == lang {
+						table.impls[k] = append(table.impls[k], n)
+					}
+				}
+			}
+		}
+		for _, r := range linkedRefs {
+			addRef(r)
+		}
+	}
+	table.link()
+
+	// If a focus symbol is provided, perform a breadth-first search from it
+	// and boost weights for visited symbols/files to prioritize them.
+	sa := &symbolAnalysis{}
+	edges := map[[2]symbolNode]struct{}{}
+	visited := map[symbolNode]struct{}{}
+	if cfg.Focus != "" {
+		// use is a name to resolve and the symbol it is used from.
+		type use struct {
+			name string
+			from symbolNode
+		}
+		queue := []use{{name: cfg.Focus}}
+		depth := 0
+		nextQueue := []use{}
+		// visit marks symbol n with weight and enqueues its references.
+		visit := func(n symbolNode, weight int) {
+			visited[n] = struct{}{}
+			// mark the declaring file's weight high
+			ctx.Files[fileIndex[n.Path]].Weight += weight
+			// enqueue references for next level
+			for name := range refs[n] {
+				nextQueue = append(nextQueue, use{name: name, from: n})
+				if depth == cfg.Depth {
+					continue
+				}
+				// Record edges to the symbols visited next. A name that
+				// resolves only to the caller itself draws no edge.
+				direct, impls, linked := table.resolve(name, n)
+				targets := append(append(direct, impls...), linked...)
+				if len(targets) == 1 && targets[0] == n {
+					continue
+				}
+				for _, target := range targets {
+					edges[[2]symbolNode{n, target}] = struct{}{}
+				}
+			}
+		}
+		for depth <= cfg.Depth && len(queue) > 0 {
+			for _, cur := range queue {
+				// match symbol keys by exact or suffix match
+				direct, impls, linked := table.resolve(cur.name, cur.from)
+				if depth == 0 {
+					sa.Roots = append(append(append(sa.Roots, direct...), impls...), linked...)
+				}
+				for _, n := range direct {
+					visit(n, 1000)
+				}
+				// Implementations of abstract symbols and the symbols
+				// linked through a schema are weighted below direct
+				// matches.
+				for _, n := range impls {
+					visit(n, 750)
+				}
+				for _, n := range linked {
+					visit(n, 750)
+				}
+			}
+			queue = nextQueue
+			nextQueue = []use{}
+			depth++
+		}
+		// Also mark referrers of the visited symbols to preserve context,
+		// including uses in other languages of the code generated from a
+		// visited schema symbol.
+		for from, names := range refs {
+			if !table.declared(from) {
+				continue
+			}
+			for name := range names {
+				direct, impls, linked := table.resolve(name, from)
+				for _, to := range append(append(direct, impls...), linked...) {
+					if _, ok := visited[to]; !ok || to == from {
+						continue
+					}
+					edges[[2]symbolNode{from, to}] = struct{}{}
+					ctx.Files[fileIndex[from.Path]].Weight += 500
+				}
+			}
+		}
+	}
+
+	for e := range edges {
+		sa.Calls = append(sa.Calls, e)
+	}
+	return sa
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"slices"
+	"strings"
+)
+
+// cAnalyzer is the Analyzer for C and C++, built on scanC.
+type cAnalyzer struct {
+	lang string
+}
+
+func init() {
+	registerAnalyzer(cAnalyzer{lang: "c"}, "c")
+	registerAnalyzer(cAnalyzer{lang: "cpp"}, "cpp")
+}
+
+// Summarize returns includes, object-like macros, namespaces, classes,
+// structs and unions with their bases, fields and methods, enums,
+// typedefs, global variables and function signatures, both prototypes and
+// definitions. Comments directly preceding a declaration are captured as
+// its doc if cfg.Docs is set.
+func (a cAnalyzer) Summarize(src []byte, relPath string, cfg *Config) *ASTInfo {
+	return scanC(src, a.lang, cfg.Docs).AST
+}
+
+func (a cAnalyzer) Symbols(src []byte, relPath string, cfg *Config) []Symbol {
+	return defSymbols(scanC(src, a.lang, false).Defs)
+}
+
+func (a cAnalyzer) References(src []byte, relPath string, cfg *Config) []Reference {
+	return defReferences(scanC(src, a.lang, false).Defs)
+}
+
+func (a cAnalyzer) Skeleton(src []byte) ([]sourceLine, error) {
+	return scanC(src, a.lang, false).skeleton(src)
+}
+
+// cScope describes the block whose declarations are being scanned.
+type cScope struct {
+	class  string // name of the enclosing class, struct or union
+	idx    int    // index into Classes, or -1
+	public bool   // current access of class members
+	ns     string // enclosing namespace, "a::b"
+}
+
+type cParser struct {
+	*outlineParser
+}
+
+// scanC scans the declarations of a C or C++ file, descending into
+// namespaces, extern "C" blocks and class bodies. Function bodies are not
+// parsed beyond the calls they make. Preprocessor conditionals are ignored,
+// so declarations of all branches are seen.
+func scanC(src []byte, lang string, withDocs bool) *outline {
+	op, _ := newOutlineParser(src, lang, withDocs)
+	p := &cParser{op}
+	p.decls(0, cScope{idx: -1})
+	return p.out
+}
+
+// decls scans the declarations from i up to the '}' closing their block
+// and returns its index.
+func (p *cParser) decls(i int, sc cScope) int {
+	for i < len(p.toks) && !p.is(i, "}") {
+		switch {
+		case p.tok(i).Kind == '#':
+			if sc.idx < 0 {
+				p.directive(p.tok(i))
+			}
+			i++
+		case p.is(i, ";"):
+			i++
+		case sc.idx >= 0 && (p.is(i, "public") || p.is(i, "protected") || p.is(i, "private")) && p.is(i+1, ":"):
+			sc.public = p.is(i, "public")
+			i += 2
+		default:
+			i = p.decl(i, p.stmtEnd(i, true), sc)
+		}
+	}
+	return i
+}
+
+// directive records #include directives as imports and object-like
+// macros with a value as consts.
+func (p *cParser) directive(t cToken) {
+	ai := p.out.AST
+	text := strings.TrimSpace(strings.TrimPrefix(t.Text, "#"))
+	switch {
+	case strings.HasPrefix(text, "include"):
+		spec := strings.TrimSpace(strings.TrimPrefix(text, "include"))
+		if len(spec) < 2 || (spec[0] != '<' && spec[0] != '"') {
+			return
+		}
+		closing := byte('"')
+		if spec[0] == '<' {
+			closing = '>'
+		}
+		if end := strings.IndexByte(spec[1:], closing); end >= 0 {
+			ai.Imports = append(ai.Imports, spec[1:1+end])
+		}
+	case strings.HasPrefix(text, "define"):
+		rest := strings.TrimSpace(strings.TrimPrefix(text, "define"))
+		n := 0
+		for n < len(rest) && isCIdentByte(rest[n]) {
+			n++
+		}
+		if n == 0 || n < len(rest) && rest[n] == '(' {
+			return // function-like macro
+		}
+		value := rest[n:]
+		if i := strings.Index(value, "//"); i >= 0 {
+			value = value[:i]
+		}
+		value = compactSignature(strings.ReplaceAll(value, "\\\n", " "))
+		if value == "" {
+			return // include guards and flags
+		}
+		v := ValueInfo{Name: rest[:n], Exported: true}
+		if len(value) <= 80 {
+			v.Value = value
+		}
+		ai.Consts = append(ai.Consts, v)
+	}
+}
+
+// decl scans the declaration from start to end, its terminating ';' or
+// '{', and returns the index of the token after it.
+func (p *cParser) decl(start, end int, sc cScope) int {
+	if end >= len(p.toks) || p.is(end, "}") {
+		return end
+	}
+	next := end + 1
+	if p.is(end, "{") {
+		next = p.match(end) + 1
+	}
+	j := start
+	for p.is(j, "template") && p.is(j+1, "<") {
+		j = p.angleEnd(j+1) + 1
+	}
+	ai := p.out.AST
+	doc := p.doc(start)
+	kw := p.ident(j)
+	switch {
+	case kw == "namespace" || kw == "inline" && p.is(j+1, "namespace"):
+		if kw == "inline" {
+			j++
+		}
+		if !p.is(end, "{") {
+			return next // namespace alias
+		}
+		ns := p.text(j+1, end-1)
+		if sc.ns != "" && ns != "" {
+			ns = sc.ns + "::" + ns
+		}
+		if ns != "" && !slices.Contains(ai.Modules, ns) {
+			ai.Modules = append(ai.Modules, ns)
+		}
+		if ns == "" {
+			ns = sc.ns
+		}
+		return p.decls(end+1, cScope{idx: -1, ns: ns}) + 1
+	case kw == "extern" && p.tok(j+1).Kind == 's' && j+2 == end:
+		// extern "C" { ... }
+		return p.decls(end+1, sc) + 1
+	case kw == "using":
+		if name := p.ident(j + 1); name != "" && p.is(j+2, "=") {
+			ai.Types = append(ai.Types, TypeInfo{Name: name, Type: p.text(j+3, end-1), Alias: true, Doc: doc, Exported: sc.idx < 0 || sc.public})
+			p.addDef(name, "type", start, end)
+		}
+		return next
+	case kw == "friend" || kw == "static_assert":
+		return next
+	case kw == "typedef":
+		return p.typedef(start, j, end, doc)
+	}
+
+	paren := p.paramList(j, end)
+	switch kw {
+	case "class", "struct", "union", "enum":
+		if p.is(end, "{") && paren < 0 {
+			return p.record(start, j, end, sc, doc, false)
+		}
+		if end-j <= 2 || kw == "enum" {
+			return next // forward declaration
+		}
+	}
+	if paren >= 0 {
+		return p.function(start, j, paren, end, sc, doc)
+	}
+	if p.is(end, "{") {
+		return next
+	}
+
+	// A variable or field: the name is the last identifier before any
+	// initializer, array bounds or bit-field width.
+	stop, nameAt := j, -1
+	for ; stop < end && !p.is(stop, "=") && !p.is(stop, ":") && !p.is(stop, "["); stop++ {
+		if p.ident(stop) != "" {
+			nameAt = stop
+		}
+	}
+	switch {
+	case nameAt < 0:
+	case sc.idx >= 0:
+		cls := &ai.Classes[sc.idx]
+		cls.Fields = append(cls.Fields, p.text(j, stop-1))
+	case sc.class == "" && nameAt > j:
+		typ := p.text(j, nameAt-1)
+		for _, w := range []string{"extern ", "static ", "thread_local "} {
+			typ = strings.TrimPrefix(typ, w)
+		}
+		v := ValueInfo{Name: p.ident(nameAt), Type: typ, Exported: !p.hasWord(j, nameAt, "static")}
+		if p.is(stop, "=") {
+			if value := p.text(stop+1, end-1); len(value) <= 80 {
+				v.Value = value
+			}
+		}
+		ai.Vars = append(ai.Vars, v)
+	}
+	return next
+}
+
+// hasWord reports whether the identifier word appears between from and to.
+func (p *cParser) hasWord(from, to int, word string) bool {
+	for i := from; i < to; i++ {
+		if p.is(i, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// paramList returns the index of the '(' opening the parameter list of the
+// function declared between from and to, or -1 if the declaration is not a
+// function. Parentheses of attributes and of initializers are skipped.
+func (p *cParser) paramList(from, to int) int {
+	for m := from; m < to; m++ {
+		switch {
+		case p.is(m, "="):
+			return -1
+		case p.is(m, "operator"):
+			if p.is(m+1, "(") && p.is(m+2, ")") {
+				return m + 3
+			}
+			for n := m + 1; n < to; n++ {
+				if p.is(n, "(") {
+					return n
+				}
+			}
+			return -1
+		case p.is(m, "<"):
+			m = p.angleEnd(m)
+		case p.is(m, "["):
+			m = p.match(m)
+		case p.is(m, "("):
+			if name := p.ident(m - 1); name != "" && !cNotCallees[name] {
+				return m
+			}
+			m = p.match(m)
+		}
+	}
+	return -1
+}
+
+// function records the function whose parameter list opens at paren and
+// returns the index of the token after it. Out-of-line definitions such as
+// "void Foo::bar()" are methods of Foo.
+func (p *cParser) function(start, j, paren, end int, sc cScope, doc string) int {
+	ai := p.out.AST
+	nameAt := paren - 1
+	name := p.ident(nameAt)
+	for m := paren - 1; m >= j && m >= paren-3; m-- {
+		if p.is(m, "operator") {
+			nameAt, name = m, "operator"
+			if suffix := p.text(m+1, paren-1); suffix != "" && isCIdentByte(suffix[0]) {
+				name += " " + suffix
+			} else {
+				name += suffix
+			}
+			break
+		}
+	}
+	if p.is(nameAt-1, "~") {
+		nameAt--
+		name = "~" + name
+	}
+	recv := sc.class
+	if recv == "" && p.is(nameAt-1, "::") {
+		q := nameAt - 2
+		if p.is(q, ">") {
+			for depth := 0; q > j; q-- {
+				if p.is(q, ">") {
+					depth++
+				} else if p.is(q, "<") {
+					depth--
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			q--
+		}
+		recv = p.ident(q)
+	}
+
+	sigEnd := end - 1
+	for m := p.match(paren) + 1; m < end; m++ {
+		if p.is(m, ":") {
+			sigEnd = m - 1 // constructor member initializers
+			break
+		}
+	}
+	sig := p.text(start, sigEnd)
+	exported := !p.hasWord(j, nameAt, "static")
+	if sc.idx >= 0 {
+		exported = sc.public
+		cls := &ai.Classes[sc.idx]
+		cls.Methods = append(cls.Methods, sig)
+	}
+	ai.Functions = append(ai.Functions, FuncInfo{Name: name, Receiver: recv, Signature: sig, Doc: doc, Exported: exported})
+	key, kind := name, "func"
+	if recv != "" {
+		key, kind = recv+"."+name, "method"
+	}
+	def := p.addDef(key, kind, start, end)
+	if !p.is(end, "{") {
+		return end + 1
+	}
+	close := p.match(end)
+	p.addBody(def, end, close, recv)
+	return close + 1
+}
+
+// record records the class, struct, union or enum whose keyword is at j,
+// scans its body and returns the index of the token after the declarators
+// following it. For typedefs, those declarators name the type.
+func (p *cParser) record(start, j, end int, sc cScope, doc string, typedef bool) int {
+	ai := p.out.AST
+	kind := p.ident(j)
+	nameAt, colon := -1, -1
+	for m := j + 1; m < end && colon < 0; m++ {
+		switch {
+		case p.is(m, "("), p.is(m, "["):
+			m = p.match(m)
+		case p.is(m, "<"):
+			m = p.angleEnd(m)
+		case p.is(m, ":"):
+			colon = m
+		case p.ident(m) != "" && !p.is(m, "final") && !p.is(m, "class") && !p.is(m, "struct"):
+			nameAt = m
+		}
+	}
+	name := p.ident(nameAt)
+
+	close := p.match(end)
+	semi := close + 1
+	var aliases []string
+	for semi < len(p.toks) && !p.is(semi, ";") && !p.is(semi, "}") {
+		if p.ident(semi) != "" && (p.is(semi+1, ",") || p.is(semi+1, ";")) && typedef {
+			aliases = append(aliases, p.ident(semi))
+		}
+		semi++
+	}
+	if name == "" && len(aliases) > 0 {
+		name, aliases = aliases[0], aliases[1:]
+	}
+	if name == "" {
+		return semi + 1 // anonymous members are not summarized
+	}
+
+	public := sc.idx < 0 || sc.public
+	if kind == "enum" {
+		t := TypeInfo{Name: name, Type: "enum", Doc: doc, Exported: public}
+		if p.is(j+1, "class") || p.is(j+1, "struct") {
+			t.Type = "enum class"
+		}
+		ai.Types = append(ai.Types, t)
+		p.addDef(name, "type", start, semi)
+	} else {
+		ci := ClassInfo{Name: name, Doc: doc, Exported: public}
+		if kind != "class" {
+			ci.Kind = kind
+		}
+		if colon >= 0 {
+			for _, b := range p.splitTopLevel(colon+1, end-1, ",") {
+				base := p.text(b[0], b[1])
+				// "virtual" may come before or after the access specifier.
+				for _, w := range []string{"virtual ", "public ", "protected ", "private ", "virtual "} {
+					base = strings.TrimPrefix(base, w)
+				}
+				ci.Bases = append(ci.Bases, base)
+			}
+		}
+		ai.Classes = append(ai.Classes, ci)
+		p.addDef(name, "class", start, semi)
+		p.decls(end+1, cScope{class: name, idx: len(ai.Classes) - 1, public: kind != "class", ns: sc.ns})
+	}
+	for _, a := range aliases {
+		ai.Types = append(ai.Types, TypeInfo{Name: a, Type: kind + " " + name, Alias: true, Exported: public})
+	}
+	return semi + 1
+}
+
+// typedef records the typedef whose keyword is at j and returns the index
+// of the token after it.
+func (p *cParser) typedef(start, j, end int, doc string) int {
+	k := j + 1
+	switch p.ident(k) {
+	case "struct", "union", "enum", "class":
+		if p.is(end, "{") {
+			return p.record(start, k, end, cScope{idx: -1}, doc, true)
+		}
+	}
+	if p.is(end, "{") {
+		return p.match(end) + 1
+	}
+	// typedef T name[N]; or typedef R (*name)(params);
+	nameAt, fnPtr := -1, false
+	for m := k; m < end; m++ {
+		switch {
+		case p.is(m, "(") && (p.is(m+1, "*") || p.is(m+1, "^")):
+			for n := m + 1; n < p.match(m); n++ {
+				if p.ident(n) != "" {
+					nameAt = n
+				}
+			}
+			fnPtr, m = true, end
+		case p.is(m, "("), p.is(m, "["):
+			m = p.match(m)
+		case p.ident(m) != "":
+			nameAt = m
+		}
+	}
+	if nameAt < 0 {
+		return end + 1
+	}
+	name := p.ident(nameAt)
+	t := TypeInfo{Name: name, Type: p.text(k, nameAt-1), Alias: true, Doc: doc, Exported: true}
+	if fnPtr {
+		t.Type = p.text(k, end-1)
+	}
+	p.out.AST.Types = append(p.out.AST.Types, t)
+	p.addDef(name, "type", start, end)
+	return end + 1
+}
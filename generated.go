@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+// generatedHeader matches the standard "Code generated ... DO NOT EDIT."
+// marker (https://go.dev/s/generatedcode) behind the line comment styles of
+// the languages whose generators emit it: protoc, mockgen, stringer, sqlc
+// and friends.
+var generatedHeader = regexp.MustCompile(`(?m)^\s*(//|#|--|/\*|\*)\s*Code generated .* DO NOT EDIT\.`)
+
+// generatedHeaderBytes bounds how much of a file is searched for the marker,
+// which generators place at the top.
+const generatedHeaderBytes = 4096
+
+// isGenerated reports whether src carries a generated-code header.
+func isGenerated(src []byte) bool {
+	if len(src) > generatedHeaderBytes {
+		src = src[:generatedHeaderBytes]
+	}
+	return generatedHeader.Match(src)
+}
+
+// fileIsGenerated reads just the head of the file at path and reports
+// whether it carries a generated-code header.
+func fileIsGenerated(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head, err := io.ReadAll(io.LimitReader(f, generatedHeaderBytes))
+	if err != nil || bytes.IndexByte(head, 0) >= 0 {
+		return false
+	}
+	return isGenerated(head)
+}
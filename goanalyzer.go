@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// goAnalyzer is the Analyzer for Go, built on go/parser and go/types.
+type goAnalyzer struct{}
+
+func init() {
+	registerAnalyzer(goAnalyzer{}, "go")
+}
+
+func (goAnalyzer) Summarize(src []byte, relPath string, cfg *Config) *ASTInfo {
+	return parseGoASTFromBytes(src, cfg.Docs)
+}
+
+// Symbols lists top-level functions, methods (keyed "Recv.Name" with the
+// receiver's type parameters dropped) and named types.
+func (goAnalyzer) Symbols(src []byte, relPath string, cfg *Config) []Symbol {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, relPath, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	line := func(p token.Pos) int { return fset.Position(p).Line }
+	var syms []Symbol
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			kind := "func"
+			if d.Recv != nil {
+				kind = "method"
+			}
+			syms = append(syms, Symbol{Key: goFuncKey(d), Kind: kind, Start: line(d.Pos()), End: line(d.End())})
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				ts := spec.(*ast.TypeSpec)
+				syms = append(syms, Symbol{Key: ts.Name.Name, Kind: "type", Start: line(ts.Pos()), End: line(ts.End())})
+			}
+		}
+	}
+	return syms
+}
+
+// References lists the calls made from each function, as "Name", "X.Name"
+// for calls through an identifier, or the bare method name otherwise.
+func (goAnalyzer) References(src []byte, relPath string, cfg *Config) []Reference {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, relPath, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	var refs []Reference
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok {
+			return true
+		}
+		var callee string
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			callee = fun.Name
+		case *ast.SelectorExpr:
+			// could be pkg.Func or expr.Method
+			if id, ok := fun.X.(*ast.Ident); ok {
+				callee = fmt.Sprintf("%s.%s", id.Name, fun.Sel.Name)
+			} else {
+				// fallback to method name only
+				callee = fun.Sel.Name
+			}
+		}
+		if parent := findEnclosingFunc(f, call.Pos()); parent != nil && callee != "" {
+			refs = append(refs, Reference{From: goFuncKey(parent), To: callee})
+		}
+		return true
+	})
+	return refs
+}
+
+func (goAnalyzer) Skeleton(src []byte) ([]sourceLine, error) {
+	return goSkeleton(src)
+}
+
+// Link type-checks the project so that interface methods resolve to their
+// implementations, and adds a reference to "Iface.Method" for every call
+// made through an interface value.
+func (goAnalyzer) Link(files []sourceFile, cfg *Config) (map[string][]string, []Reference) {
+	fset := token.NewFileSet()
+	fileASTs := map[string]*ast.File{}
+	funcs := map[string]bool{}
+	for _, sf := range files {
+		f, err := parser.ParseFile(fset, sf.Path, sf.Src, parser.SkipObjectResolution)
+		if err != nil {
+			continue
+		}
+		fileASTs[sf.Path] = f
+		for _, decl := range f.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok {
+				funcs[goFuncKey(fd)] = true
+			}
+		}
+	}
+
+	pkgs, info := typeCheckGoPackages(fset, fileASTs, readModulePath(cfg.Path))
+	impls := interfaceImplementations(pkgs, func(key string) bool { return funcs[key] })
+
+	var refs []Reference
+	for path, f := range fileASTs {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+			if ic := interfaceCallee(info, sel); ic != "" {
+				if parent := findEnclosingFunc(f, call.Pos()); parent != nil {
+					refs = append(refs, Reference{From: goFuncKey(parent), To: ic, Path: path})
+				}
+			}
+			return true
+		})
+	}
+	return impls, refs
+}
+
+// goFuncKey returns the symbol key of a function: its name, prefixed with
+// the receiver type for methods to disambiguate.
+func goFuncKey(fd *ast.FuncDecl) string {
+	if fd.Recv != nil && len(fd.Recv.List) > 0 {
+		return recvTypeName(fd.Recv.List[0].Type) + "." + fd.Name.Name
+	}
+	return fd.Name.Name
+}
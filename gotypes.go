@@ -0,0 +1,210 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// projectImporter type-checks project packages from the already parsed
+// files. Imports outside the module resolve to empty placeholder packages,
+// so checking never needs compiled dependencies; the resulting type errors
+// are ignored since only the project's own method sets are of interest.
+type projectImporter struct {
+	fset       *token.FileSet
+	modulePath string
+	// dirs maps a directory to its files grouped by package name.
+	dirs map[string]map[string][]*ast.File
+	info *types.Info
+	pkgs map[string]*types.Package
+}
+
+// typeCheckGoPackages type-checks the parsed project files package by
+// package and returns the checked packages together with shared type info.
+// files is keyed by project-relative path.
+func typeCheckGoPackages(fset *token.FileSet, files map[string]*ast.File, modulePath string) ([]*types.Package, *types.Info) {
+	pi := &projectImporter{
+		fset:       fset,
+		modulePath: modulePath,
+		dirs:       map[string]map[string][]*ast.File{},
+		info:       &types.Info{Selections: map[*ast.SelectorExpr]*types.Selection{}},
+		pkgs:       map[string]*types.Package{},
+	}
+	// Sort paths so that checking order, and thus results, are stable.
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		f := files[p]
+		dir := filepath.ToSlash(filepath.Dir(p))
+		if pi.dirs[dir] == nil {
+			pi.dirs[dir] = map[string][]*ast.File{}
+		}
+		pi.dirs[dir][f.Name.Name] = append(pi.dirs[dir][f.Name.Name], f)
+	}
+
+	var checked []*types.Package
+	dirs := make([]string, 0, len(pi.dirs))
+	for dir := range pi.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		importPath := path.Join(modulePath, dir)
+		for name, pkgFiles := range pi.dirs[dir] {
+			if strings.HasSuffix(name, "_test") {
+				checked = append(checked, pi.check(importPath+"_test", pkgFiles))
+			}
+		}
+		if len(pi.packageFiles(dir)) > 0 {
+			pkg, _ := pi.Import(importPath)
+			checked = append(checked, pkg)
+		}
+	}
+	return checked, pi.info
+}
+
+// Import implements types.Importer.
+func (pi *projectImporter) Import(importPath string) (*types.Package, error) {
+	if pkg, ok := pi.pkgs[importPath]; ok {
+		return pkg, nil
+	}
+	if dir, ok := pi.dirFor(importPath); ok {
+		if files := pi.packageFiles(dir); len(files) > 0 {
+			// Register a placeholder first to break import cycles.
+			pi.pkgs[importPath] = types.NewPackage(importPath, files[0].Name.Name)
+			pkg := pi.check(importPath, files)
+			pi.pkgs[importPath] = pkg
+			return pkg, nil
+		}
+	}
+	pkg := types.NewPackage(importPath, guessPackageName(importPath))
+	pkg.MarkComplete()
+	pi.pkgs[importPath] = pkg
+	return pkg, nil
+}
+
+// check type-checks one package, ignoring errors.
+func (pi *projectImporter) check(importPath string, files []*ast.File) *types.Package {
+	conf := types.Config{Importer: pi, Error: func(error) {}}
+	pkg, _ := conf.Check(importPath, pi.fset, files, pi.info)
+	return pkg
+}
+
+// dirFor maps an import path inside the module to a project directory.
+func (pi *projectImporter) dirFor(importPath string) (string, bool) {
+	if pi.modulePath == "" {
+		_, ok := pi.dirs[importPath]
+		return importPath, ok
+	}
+	if importPath == pi.modulePath {
+		return ".", true
+	}
+	rel, ok := strings.CutPrefix(importPath, pi.modulePath+"/")
+	return rel, ok
+}
+
+// packageFiles returns the files of the non-test package in dir.
+func (pi *projectImporter) packageFiles(dir string) []*ast.File {
+	names := make([]string, 0, len(pi.dirs[dir]))
+	for name := range pi.dirs[dir] {
+		if !strings.HasSuffix(name, "_test") {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+	return pi.dirs[dir][names[0]]
+}
+
+// guessPackageName derives a package name from an import path, dropping
+// major version suffixes and common decorations like "go-" or ".v3".
+func guessPackageName(importPath string) string {
+	parts := strings.Split(importPath, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && len(name) > 1 && name[0] == 'v' && strings.Trim(name[1:], "0123456789") == "" {
+		name = parts[len(parts)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.Index(name, "."); i > 0 {
+		name = name[:i]
+	}
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+// interfaceImplementations maps each interface method of the checked
+// packages, keyed as "Iface.Method", to the function keys of the concrete
+// methods implementing it. Only methods for which hasFunc reports a
+// declaration in the project are returned; generic types are skipped.
+func interfaceImplementations(pkgs []*types.Package, hasFunc func(string) bool) map[string][]string {
+	var ifaces, concrete []*types.Named
+	for _, pkg := range pkgs {
+		if pkg == nil {
+			continue
+		}
+		scope := pkg.Scope()
+		for _, name := range scope.Names() {
+			tn, ok := scope.Lookup(name).(*types.TypeName)
+			if !ok || tn.IsAlias() {
+				continue
+			}
+			named, ok := tn.Type().(*types.Named)
+			if !ok || named.TypeParams().Len() > 0 {
+				continue
+			}
+			if types.IsInterface(named) {
+				ifaces = append(ifaces, named)
+			} else {
+				concrete = append(concrete, named)
+			}
+		}
+	}
+
+	impls := map[string][]string{}
+	for _, iface := range ifaces {
+		it := iface.Underlying().(*types.Interface)
+		if it.NumMethods() == 0 {
+			continue
+		}
+		for _, t := range concrete {
+			if !types.Implements(t, it) && !types.Implements(types.NewPointer(t), it) {
+				continue
+			}
+			for i := 0; i < it.NumMethods(); i++ {
+				m := it.Method(i).Name()
+				key := iface.Obj().Name() + "." + m
+				for _, impl := range []string{t.Obj().Name() + "." + m, "*" + t.Obj().Name() + "." + m} {
+					if hasFunc(impl) {
+						impls[key] = append(impls[key], impl)
+					}
+				}
+			}
+		}
+	}
+	for key := range impls {
+		sort.Strings(impls[key])
+	}
+	return impls
+}
+
+// interfaceCallee returns "Iface.Method" if sel is a method call through a
+// value of a named interface type, or "" otherwise.
+func interfaceCallee(info *types.Info, sel *ast.SelectorExpr) string {
+	s, ok := info.Selections[sel]
+	if !ok || s.Kind() != types.MethodVal || !types.IsInterface(s.Recv()) {
+		return ""
+	}
+	named, ok := s.Recv().(*types.Named)
+	if !ok {
+		return ""
+	}
+	return named.Obj().Name() + "." + sel.Sel.Name
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// GraphInfo holds rendered dependency graphs in a text format (Mermaid or DOT).
+type GraphInfo struct {
+	Format   string `json:"format" yaml:"format"`
+	Packages string `json:"packages,omitempty" yaml:"packages,omitempty"`
+	Calls    string `json:"calls,omitempty" yaml:"calls,omitempty"`
+}
+
+// callEdge is a caller -> callee edge between function keys.
+type callEdge struct {
+	From string
+	To   string
+}
+
+// buildGraphs renders the package dependency graph from the package index
+// and the focused call graph from calls. External imports are only drawn if
+// enabled, collapsed to their module root; the standard library is omitted.
+// A graph without edges is left out, and nil returned if both are.
+func buildGraphs(ctx *Context, cfg *Config, modulePath string, calls []callEdge) *GraphInfo {
+	internal := map[string]bool{}
+	var nodes []string
+	for _, pkg := range ctx.Packages {
+		internal[pkg.ImportPath] = true
+		nodes = append(nodes, pkg.ImportPath)
+	}
+
+	edgeSet := map[callEdge]struct{}{}
+	for _, pkg := range ctx.Packages {
+		for _, imp := range pkg.Imports {
+			edgeSet[callEdge{From: pkg.ImportPath, To: imp}] = struct{}{}
+		}
+	}
+	if cfg.GraphExternal {
+		external := map[string]bool{}
+		for i := range ctx.Files {
+			f := &ctx.Files[i]
+			if f.AST == nil || f.Language != "go" {
+				continue
+			}
+			from := packageImportPath(modulePath, f)
+			for _, imp := range f.AST.Imports {
+				if internal[imp] || isStdlibImport(imp) {
+					continue
+				}
+				root := moduleRoot(imp)
+				if !external[root] {
+					external[root] = true
+					nodes = append(nodes, root)
+				}
+				edgeSet[callEdge{From: from, To: root}] = struct{}{}
+			}
+		}
+	}
+	edges := make([]callEdge, 0, len(edgeSet))
+	for e := range edgeSet {
+		edges = append(edges, e)
+	}
+	sortEdges(edges)
+	sort.Strings(nodes)
+
+	gi := &GraphInfo{Format: cfg.GraphFormat}
+	if len(edges) > 0 {
+		gi.Packages = renderGraph(cfg.GraphFormat, "packages", nodes, edges)
+	}
+	if len(calls) > 0 {
+		seen := map[string]bool{}
+		var callNodes []string
+		for _, e := range calls {
+			for _, n := range []string{e.From, e.To} {
+				if !seen[n] {
+					seen[n] = true
+					callNodes = append(callNodes, n)
+				}
+			}
+		}
+		sort.Strings(callNodes)
+		gi.Calls = renderGraph(cfg.GraphFormat, "calls", callNodes, calls)
+	}
+	if gi.Packages == "" && gi.Calls == "" {
+		return nil
+	}
+	return gi
+}
+
+// renderGraph renders a directed graph as Mermaid or DOT.
+func renderGraph(format, name string, nodes []string, edges []callEdge) string {
+	var b strings.Builder
+	if format == "dot" {
+		fmt.Fprintf(&b, "digraph %s {\n", name)
+		for _, n := range nodes {
+			fmt.Fprintf(&b, "  %q;\n", n)
+		}
+		for _, e := range edges {
+			fmt.Fprintf(&b, "  %q -> %q;\n", e.From, e.To)
+		}
+		b.WriteString("}\n")
+		return b.String()
+	}
+
+	// Mermaid node ids must be simple identifiers; labels carry the names.
+	ids := map[string]string{}
+	b.WriteString("graph LR\n")
+	for i, n := range nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[n], strings.ReplaceAll(n, `"`, "#quot;"))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], ids[e.To])
+	}
+	return b.String()
+}
+
+// sortEdges orders edges by source, then target.
+func sortEdges(edges []callEdge) {
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+}
+
+// isStdlibImport reports whether an import path belongs to the standard
+// library, whose first path element never contains a dot.
+func isStdlibImport(imp string) bool {
+	first, _, _ := strings.Cut(imp, "/")
+	return !strings.Contains(first, ".")
+}
+
+// moduleRoot collapses an import path to its likely module root: three
+// elements for well-known code hosts (host/owner/repo), otherwise the first
+// two. A major version suffix such as /v4 is kept.
+func moduleRoot(imp string) string {
+	parts := strings.Split(imp, "/")
+	n := 2
+	switch parts[0] {
+	case "github.com", "gitlab.com", "bitbucket.org":
+		n = 3
+	}
+	if len(parts) > n && len(parts[n]) > 1 && parts[n][0] == 'v' && strings.Trim(parts[n][1:], "0123456789") == "" {
+		n++
+	}
+	if len(parts) < n {
+		return imp
+	}
+	return strings.Join(parts[:n], "/")
+}
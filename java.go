@@ -0,0 +1,274 @@
+package main
+
+// javaAnalyzer is the Analyzer for Java, built on scanJava.
+type javaAnalyzer struct{}
+
+func init() {
+	registerAnalyzer(javaAnalyzer{}, "java")
+}
+
+// Summarize returns the package, imports, classes, interfaces, enums and
+// records with their annotations, bases, fields and method signatures.
+// Javadoc comments are captured if cfg.Docs is set.
+func (javaAnalyzer) Summarize(src []byte, relPath string, cfg *Config) *ASTInfo {
+	return scanJava(src, cfg.Docs).AST
+}
+
+func (javaAnalyzer) Symbols(src []byte, relPath string, cfg *Config) []Symbol {
+	return defSymbols(scanJava(src, false).Defs)
+}
+
+func (javaAnalyzer) References(src []byte, relPath string, cfg *Config) []Reference {
+	return defReferences(scanJava(src, false).Defs)
+}
+
+func (javaAnalyzer) Skeleton(src []byte) ([]sourceLine, error) {
+	return scanJava(src, false).skeleton(src)
+}
+
+// javaModifiers may precede a Java declaration.
+var javaModifiers = map[string]bool{
+	"public": true, "protected": true, "private": true, "static": true,
+	"final": true, "abstract": true, "native": true, "synchronized": true,
+	"transient": true, "volatile": true, "strictfp": true, "default": true,
+	"sealed": true,
+}
+
+// javaScope describes the type body whose members are being scanned.
+type javaScope struct {
+	class string // qualified name of the enclosing type, "Outer.Inner"
+	idx   int    // index into Classes, or -1
+	iface int    // index into Interfaces, or -1
+	enum  bool
+}
+
+type javaParser struct {
+	*outlineParser
+}
+
+// scanJava scans the declarations of a Java file, descending into nested
+// types. Method bodies are not parsed beyond the calls they make.
+func scanJava(src []byte, withDocs bool) *outline {
+	op, _ := newOutlineParser(src, "java", withDocs)
+	p := &javaParser{op}
+	p.members(0, javaScope{idx: -1, iface: -1})
+	return p.out
+}
+
+// members scans the members from i up to the '}' closing their type body
+// and returns its index.
+func (p *javaParser) members(i int, sc javaScope) int {
+	if sc.enum {
+		i = p.enumConstants(i, sc)
+	}
+	for i < len(p.toks) && !p.is(i, "}") {
+		if p.is(i, ";") {
+			i++
+			continue
+		}
+		i = p.member(i, p.stmtEnd(i, false), sc)
+	}
+	return i
+}
+
+// enumConstants records the constants listed at the start of an enum body
+// and returns the index of the token after them.
+func (p *javaParser) enumConstants(i int, sc javaScope) int {
+	cls := &p.out.AST.Classes[sc.idx]
+	for i < len(p.toks) && !p.is(i, ";") && !p.is(i, "}") {
+		_, k := p.annotations(i)
+		if name := p.ident(k); name != "" {
+			cls.Fields = append(cls.Fields, name)
+		}
+		for k < len(p.toks) && !p.is(k, ",") && !p.is(k, ";") && !p.is(k, "}") {
+			if p.is(k, "(") || p.is(k, "{") {
+				k = p.match(k)
+			}
+			k++
+		}
+		i = k
+		if p.is(i, ",") {
+			i++
+		}
+	}
+	if p.is(i, ";") {
+		i++
+	}
+	return i
+}
+
+// annotations skips the annotations at i and returns them, without their
+// '@', with the index of the first token after them.
+func (p *javaParser) annotations(i int) ([]string, int) {
+	var annos []string
+	for p.is(i, "@") && !p.is(i+1, "interface") {
+		k := i + 1
+		for p.ident(k) != "" && p.is(k+1, ".") {
+			k += 2
+		}
+		end := k
+		if p.is(k+1, "(") {
+			end = p.match(k + 1)
+		}
+		annos = append(annos, p.text(i+1, end))
+		i = end + 1
+	}
+	return annos, i
+}
+
+// member scans the declaration from start to end, its terminating ';' or
+// '{', and returns the index of the token after it.
+func (p *javaParser) member(start, end int, sc javaScope) int {
+	if end >= len(p.toks) || p.is(end, "}") {
+		return end
+	}
+	next := end + 1
+	if p.is(end, "{") {
+		next = p.match(end) + 1
+	}
+	annos, j := p.annotations(start)
+	sigFrom := j
+	public := sc.iface >= 0
+	for {
+		if p.is(j, "non") && p.is(j+1, "-") && p.is(j+2, "sealed") {
+			j += 3
+			continue
+		}
+		if !javaModifiers[p.ident(j)] {
+			break
+		}
+		public = public || p.is(j, "public")
+		j++
+	}
+
+	ai := p.out.AST
+	doc := p.doc(start)
+	switch kw := p.ident(j); {
+	case sc.class == "" && kw == "package":
+		ai.Package = p.text(j+1, end-1)
+		ai.Doc = doc
+	case sc.class == "" && kw == "import":
+		ai.Imports = append(ai.Imports, p.text(j+1, end-1))
+	case kw == "class", kw == "interface", kw == "enum", kw == "record", p.is(j, "@") && p.is(j+1, "interface"):
+		p.typeDecl(start, j, end, sc, public, annos, doc)
+	case j < end && sc.class != "":
+		return p.method(start, sigFrom, j, end, sc, public, annos, doc)
+	}
+	return next
+}
+
+// typeDecl records the type whose keyword is at j and scans its body.
+func (p *javaParser) typeDecl(start, j, end int, sc javaScope, public bool, annos []string, doc string) {
+	ai := p.out.AST
+	kind, k := p.ident(j), j+1
+	if kind == "" {
+		kind, k = "@interface", j+2
+	}
+	name := p.ident(k)
+	if sc.class != "" {
+		name = sc.class + "." + name
+	}
+	k++
+	typeParams := ""
+	if p.is(k, "<") {
+		e := p.angleEnd(k)
+		typeParams = p.text(k, e)
+		k = e + 1
+	}
+	var components []string
+	if kind == "record" && p.is(k, "(") {
+		close := p.match(k)
+		for _, c := range p.splitTopLevel(k+1, close-1, ",") {
+			components = append(components, p.text(c[0], c[1]))
+		}
+		k = close + 1
+	}
+	var bases []string
+	for k < end {
+		if !p.is(k, "extends") && !p.is(k, "implements") {
+			k++
+			continue
+		}
+		stop := k + 1
+		for stop < end && !p.is(stop, "implements") && !p.is(stop, "permits") {
+			stop++
+		}
+		for _, b := range p.splitTopLevel(k+1, stop-1, ",") {
+			bases = append(bases, p.text(b[0], b[1]))
+		}
+		k = stop
+	}
+
+	last := end
+	if p.is(end, "{") {
+		last = p.match(end)
+	}
+	body := javaScope{class: name, idx: -1, iface: -1}
+	if kind == "interface" {
+		ai.Interfaces = append(ai.Interfaces, InterfaceInfo{Name: name, TypeParams: typeParams, Doc: doc, Embeds: bases, Exported: public})
+		body.iface = len(ai.Interfaces) - 1
+		p.addDef(name, "type", start, last)
+	} else {
+		ci := ClassInfo{Name: name + typeParams, Bases: bases, Decorators: annos, Doc: doc, Fields: components, Exported: public}
+		if kind != "class" {
+			ci.Kind = kind
+		}
+		ai.Classes = append(ai.Classes, ci)
+		body.idx, body.enum = len(ai.Classes)-1, kind == "enum"
+		p.addDef(name, "class", start, last)
+	}
+	if p.is(end, "{") {
+		p.members(end+1, body)
+	}
+}
+
+// method records the method, constructor or field declared from start to
+// end and returns the index of the token after it. Initializer blocks are
+// skipped.
+func (p *javaParser) method(start, sigFrom, j, end int, sc javaScope, public bool, annos []string, doc string) int {
+	ai := p.out.AST
+	// A method has its parameter list before any initializer.
+	paren := -1
+	for m := j; m < end && paren < 0; m++ {
+		switch {
+		case p.is(m, "="):
+			m = end
+		case p.is(m, "<"):
+			m = p.angleEnd(m)
+		case p.is(m, "["):
+			m = p.match(m)
+		case p.is(m, "("):
+			paren = m
+		}
+	}
+	if paren < 0 || p.ident(paren-1) == "" {
+		stop := j
+		for stop < end && !p.is(stop, "=") {
+			stop++
+		}
+		if sc.idx >= 0 {
+			cls := &ai.Classes[sc.idx]
+			cls.Fields = append(cls.Fields, p.text(sigFrom, stop-1))
+		}
+		if p.is(end, "{") {
+			return p.match(end) + 1
+		}
+		return end + 1
+	}
+
+	name := p.ident(paren - 1)
+	sig := p.text(sigFrom, end-1)
+	if sc.iface >= 0 {
+		ai.Interfaces[sc.iface].Methods = append(ai.Interfaces[sc.iface].Methods, sig)
+	} else if sc.idx >= 0 {
+		ai.Classes[sc.idx].Methods = append(ai.Classes[sc.idx].Methods, sig)
+	}
+	ai.Functions = append(ai.Functions, FuncInfo{Name: name, Receiver: sc.class, Signature: sig, Decorators: annos, Doc: doc, Exported: public})
+	def := p.addDef(sc.class+"."+name, "method", start, end)
+	if !p.is(end, "{") {
+		return end + 1
+	}
+	close := p.match(end)
+	p.addBody(def, end, close, sc.class)
+	return close + 1
+}
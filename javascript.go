@@ -0,0 +1,1209 @@
+package main
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// jsToken is a lexical token of JavaScript or TypeScript source. Kind is
+// 'i' for identifiers and keywords, 's' for string literals, 't' for
+// template literal text, 'r' for regular expression literals, 'n' for
+// numbers and 'p' for punctuation.
+type jsToken struct {
+	Kind     byte
+	Text     string
+	Line     int
+	Off, End int
+	// Doc is the JSDoc comment directly preceding the token, if any.
+	Doc string
+}
+
+// jsModule is the result of scanning one JavaScript or TypeScript file.
+type jsModule struct {
+	AST  *ASTInfo
+	Defs []defSpan
+}
+
+// jsExtensions are tried, in order, when resolving relative import specifiers.
+var jsExtensions = []string{".ts", ".tsx", ".js", ".jsx", ".mjs", ".cjs"}
+
+// jsRegexKeywords are keywords after which a slash starts a regular expression.
+var jsRegexKeywords = map[string]bool{
+	"return": true, "typeof": true, "case": true, "do": true, "else": true,
+	"in": true, "of": true, "new": true, "delete": true, "void": true,
+	"throw": true, "instanceof": true, "yield": true, "await": true,
+}
+
+// jsNotCallees are keywords that may directly precede an opening parenthesis.
+var jsNotCallees = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "catch": true,
+	"function": true, "return": true, "typeof": true, "super": true,
+	"import": true, "require": true, "with": true, "await": true, "void": true,
+}
+
+// jsMemberModifiers may precede a class member name.
+var jsMemberModifiers = map[string]bool{
+	"public": true, "private": true, "protected": true, "static": true,
+	"async": true, "get": true, "set": true, "readonly": true,
+	"abstract": true, "override": true, "declare": true,
+}
+
+// jsAnalyzer is the Analyzer for JavaScript and TypeScript, built on scanJS.
+type jsAnalyzer struct{}
+
+func init() {
+	registerAnalyzer(jsAnalyzer{}, "javascript", "typescript", "jsx", "tsx")
+}
+
+// Summarize returns ES module imports and exports, classes, functions,
+// React components and TypeScript interfaces, type aliases and enums.
+// Relative import specifiers are resolved to project files. JSDoc comments
+// are captured if cfg.Docs is set.
+func (jsAnalyzer) Summarize(src []byte, relPath string, cfg *Config) *ASTInfo {
+	return scanJS(src, relPath, cfg.Path, cfg.Docs).AST
+}
+
+func (jsAnalyzer) Symbols(src []byte, relPath string, cfg *Config) []Symbol {
+	return defSymbols(scanJS(src, relPath, cfg.Path, false).Defs)
+}
+
+func (jsAnalyzer) References(src []byte, relPath string, cfg *Config) []Reference {
+	return defReferences(scanJS(src, relPath, cfg.Path, false).Defs)
+}
+
+func (jsAnalyzer) Skeleton(src []byte) ([]sourceLine, error) {
+	return nil, errNoSkeleton
+}
+
+// jsFrame is an open brace block.
+type jsFrame struct {
+	kind   byte   // 'c' class body, 'i' interface body, 'f' function body, 'b' other
+	class  string // enclosing top-level class, for this.method() calls
+	def    int    // index into Defs owning calls, or -1
+	owns   bool   // the frame is the body of Defs[def]
+	iface  int    // index into Interfaces for interface bodies
+	parens int    // paren depth when the frame was opened
+}
+
+// jsParser walks the token stream of one file.
+type jsParser struct {
+	src         string
+	toks        []jsToken
+	relPath     string
+	projectPath string
+	withDocs    bool
+	mod         *jsModule
+
+	frames  []jsFrame
+	pending *jsFrame // frame opened by the next '{'
+	parens  int
+
+	// expr tracks an arrow function with an expression body.
+	expr       int
+	exprFrames int
+	exprParens int
+
+	aliases    map[string]string // local import name -> imported name
+	exports    map[string]bool
+	components []string
+	seen       map[string]bool
+}
+
+// scanJS lexes and parses a JavaScript or TypeScript file.
+func scanJS(src []byte, relPath, projectPath string, withDocs bool) *jsModule {
+	ai := &ASTInfo{Imports: []string{}, Structs: []StructInfo{}, Functions: []FuncInfo{}}
+	p := &jsParser{
+		src:         string(src),
+		toks:        jsTokens(string(src)),
+		relPath:     relPath,
+		projectPath: projectPath,
+		withDocs:    withDocs,
+		mod:         &jsModule{AST: ai},
+		expr:        -1,
+		aliases:     map[string]string{},
+		exports:     map[string]bool{},
+		seen:        map[string]bool{},
+	}
+	p.parse()
+
+	for i := range ai.Functions {
+		fn := &ai.Functions[i]
+		if fn.Receiver == "" && p.exports[fn.Name] {
+			fn.Exported = true
+		}
+	}
+	for i := range ai.Classes {
+		if p.exports[ai.Classes[i].Name] {
+			ai.Classes[i].Exported = true
+		}
+	}
+	for i := range ai.Interfaces {
+		if p.exports[ai.Interfaces[i].Name] {
+			ai.Interfaces[i].Exported = true
+		}
+	}
+	for i := range ai.Types {
+		if p.exports[ai.Types[i].Name] {
+			ai.Types[i].Exported = true
+		}
+	}
+	for name := range p.exports {
+		ai.Exports = append(ai.Exports, name)
+	}
+	sort.Strings(ai.Exports)
+
+	// Capitalized functions and component classes are React components in
+	// JSX files or files importing React.
+	ext := strings.ToLower(filepath.Ext(relPath))
+	react := ext == ".jsx" || ext == ".tsx"
+	for _, imp := range ai.Imports {
+		if imp == "react" || imp == "preact" {
+			react = true
+		}
+	}
+	if react {
+		ai.Components = p.components
+	}
+	return p.mod
+}
+
+func (p *jsParser) tok(i int) jsToken {
+	if i < 0 || i >= len(p.toks) {
+		return jsToken{}
+	}
+	return p.toks[i]
+}
+
+// is reports whether the token at i is the punctuation or identifier text.
+func (p *jsParser) is(i int, text string) bool {
+	t := p.tok(i)
+	return (t.Kind == 'p' || t.Kind == 'i') && t.Text == text
+}
+
+// text returns the compacted source text of tokens from..to inclusive.
+func (p *jsParser) text(from, to int) string {
+	if from > to || from < 0 || to >= len(p.toks) {
+		return ""
+	}
+	return compactSignature(p.src[p.toks[from].Off:p.toks[to].End])
+}
+
+// skipBalanced returns the index of the bracket closing the one at i.
+// Angle brackets only balance among themselves, for type parameter lists.
+func (p *jsParser) skipBalanced(i int) int {
+	if p.is(i, "<") {
+		depth := 0
+		for j := i; j < len(p.toks); j++ {
+			switch {
+			case p.is(j, "<"):
+				depth++
+			case p.is(j, ">"):
+				depth--
+				if depth == 0 {
+					return j
+				}
+			case p.is(j, "=>"):
+				depth-- // the '>' of an arrow inside a type
+			case p.is(j, "{"), p.is(j, ";"):
+				return j - 1
+			}
+		}
+		return len(p.toks) - 1
+	}
+	depth := 0
+	for j := i; j < len(p.toks); j++ {
+		switch {
+		case p.is(j, "("), p.is(j, "["), p.is(j, "{"):
+			depth++
+		case p.is(j, ")"), p.is(j, "]"), p.is(j, "}"):
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(p.toks) - 1
+}
+
+// bodyStart scans from i to the first '{', ';' or '=>' outside brackets,
+// skipping return type annotations, and returns its index.
+func (p *jsParser) bodyStart(i int) int {
+	for j := i; j < len(p.toks); j++ {
+		switch {
+		case p.is(j, "{"), p.is(j, ";"), p.is(j, "=>"):
+			return j
+		case p.is(j, "("), p.is(j, "["):
+			j = p.skipBalanced(j)
+		case p.is(j, "}"):
+			return j
+		}
+	}
+	return len(p.toks)
+}
+
+// stmtStart reports whether the token at i starts a statement or member:
+// it follows a brace or semicolon, or opens a new line after a token that
+// cannot continue an expression.
+func (p *jsParser) stmtStart(i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, t := p.tok(i-1), p.tok(i)
+	if prev.Kind == 'p' {
+		switch prev.Text {
+		case "{", "}", ";":
+			return true
+		case ",", "=", "=>", "(", "[", ".", "?.", "+", "-", "*", "/", "%", "&", "|", "?", ":", "<", ">", "!", "...":
+			return false
+		}
+	}
+	if prev.Kind == 'i' && (prev.Text == "export" || prev.Text == "default" || prev.Text == "declare") {
+		return true
+	}
+	return t.Line > prev.Line
+}
+
+// owner returns the def index calls at the current position belong to.
+func (p *jsParser) owner() (def int, class string) {
+	if p.expr >= 0 {
+		def = p.expr
+	} else {
+		def = -1
+	}
+	if n := len(p.frames); n > 0 {
+		if def < 0 {
+			def = p.frames[n-1].def
+		}
+		class = p.frames[n-1].class
+	}
+	return def, class
+}
+
+// topLevel reports whether declarations at the current position are
+// summarized: outside any function or class body.
+func (p *jsParser) topLevel() bool {
+	def, class := p.owner()
+	return def < 0 && class == ""
+}
+
+func (p *jsParser) addImport(spec string) {
+	imp := resolveJSImport(p.projectPath, p.relPath, spec)
+	if !p.seen[imp] {
+		p.seen[imp] = true
+		p.mod.AST.Imports = append(p.mod.AST.Imports, imp)
+	}
+}
+
+// addDef records a function or method and returns its index.
+func (p *jsParser) addDef(key string, fn FuncInfo, line int, docTok jsToken) int {
+	if p.withDocs {
+		fn.Doc = jsDocText(docTok.Doc)
+	}
+	p.mod.AST.Functions = append(p.mod.AST.Functions, fn)
+	kind := "func"
+	if fn.Receiver != "" {
+		kind = "method"
+	}
+	p.mod.Defs = append(p.mod.Defs, defSpan{Key: key, Kind: kind, Start: line, End: line})
+	if fn.Receiver == "" && fn.Name != "" && unicode.IsUpper(rune(fn.Name[0])) {
+		p.components = append(p.components, fn.Name)
+	}
+	return len(p.mod.Defs) - 1
+}
+
+func (p *jsParser) addCall(name string) {
+	def, _ := p.owner()
+	if def >= 0 {
+		p.mod.Defs[def].Calls = append(p.mod.Defs[def].Calls, name)
+	}
+}
+
+func (p *jsParser) parse() {
+	exported := false
+	for i := 0; i < len(p.toks); i++ {
+		t := p.toks[i]
+
+		// End an expression-bodied arrow function.
+		if p.expr >= 0 {
+			n := len(p.frames)
+			switch {
+			case n < p.exprFrames, n == p.exprFrames && p.parens < p.exprParens,
+				n == p.exprFrames && p.parens == p.exprParens && (p.is(i, ";") || p.is(i, ",") || p.stmtStart(i)):
+				p.mod.Defs[p.expr].End = p.tok(i - 1).Line
+				p.expr = -1
+			}
+		}
+
+		// The export keyword applies to the declaration right after it.
+		start := p.stmtStart(i)
+		if start && !p.is(i-1, "export") && !p.is(i-1, "default") && !p.is(i-1, "declare") && !p.is(i-1, "async") {
+			exported = false
+		}
+		top := jsFrame{def: -1}
+		if n := len(p.frames); n > 0 {
+			top = p.frames[n-1]
+		}
+
+		// Class members and interface members are recognized at their start.
+		if start && top.kind == 'c' && p.parens == top.parens && !p.is(i, "}") {
+			if next, ok := p.classMember(i, top); ok {
+				i = next
+				continue
+			}
+		}
+		if start && top.kind == 'i' && !p.is(i, "}") {
+			i = p.interfaceMember(i, top)
+			continue
+		}
+
+		switch t.Kind {
+		case 'p':
+			switch t.Text {
+			case "{":
+				f := jsFrame{kind: 'b', class: top.class, def: top.def, parens: p.parens}
+				if p.expr >= 0 && f.def < 0 {
+					f.def = p.expr
+				}
+				if p.pending != nil {
+					f = *p.pending
+					f.parens = p.parens
+					p.pending = nil
+				}
+				p.frames = append(p.frames, f)
+			case "}":
+				if n := len(p.frames); n > 0 {
+					if f := p.frames[n-1]; f.owns {
+						p.mod.Defs[f.def].End = t.Line
+					}
+					p.frames = p.frames[:n-1]
+				}
+			case "(", "[":
+				p.parens++
+			case ")", "]":
+				p.parens--
+			case ";":
+				p.pending = nil
+			case "<":
+				// JSX elements reference components: <Button ...>.
+				prev := p.tok(i - 1)
+				next := p.tok(i + 1)
+				if next.Kind == 'i' && unicode.IsUpper(rune(next.Text[0])) && next.Off == t.End &&
+					(prev.Kind == 'p' && prev.Text != ")" && prev.Text != "]" || prev.Kind == 'i' && jsRegexKeywords[prev.Text] || i == 0) {
+					name := next.Text
+					for j := i + 2; p.is(j, ".") && p.tok(j+1).Kind == 'i'; j += 2 {
+						name = p.tok(j + 1).Text
+					}
+					p.addCall(name)
+				}
+			}
+			continue
+		case 'i':
+		default:
+			continue
+		}
+
+		switch t.Text {
+		case "export":
+			exported = true
+			if p.is(i+1, "default") {
+				p.exports["default"] = true
+			}
+			if p.is(i+1, "{") || p.is(i+1, "*") {
+				i = p.exportList(i + 1)
+			}
+			continue
+		case "import":
+			if p.is(i+1, "(") && p.tok(i+2).Kind == 's' {
+				p.addImport(jsStringValue(p.tok(i + 2).Text))
+				continue
+			}
+			if p.is(i+1, ".") || !start {
+				continue // import.meta
+			}
+			i = p.importDecl(i)
+			continue
+		case "require":
+			if p.is(i+1, "(") && p.tok(i+2).Kind == 's' {
+				p.addImport(jsStringValue(p.tok(i + 2).Text))
+			}
+			continue
+		case "function":
+			i = p.functionDecl(i, exported)
+			continue
+		case "class":
+			name := ""
+			if p.tok(i+1).Kind == 'i' && !p.is(i+1, "extends") {
+				name = p.tok(i + 1).Text
+			}
+			i = p.classDecl(i, name, exported)
+			continue
+		case "interface":
+			if start && p.tok(i+1).Kind == 'i' && (p.is(i+2, "{") || p.is(i+2, "<") || p.is(i+2, "extends")) {
+				i = p.interfaceDecl(i, exported)
+				continue
+			}
+		case "type":
+			if start && p.tok(i+1).Kind == 'i' && (p.is(i+2, "=") || p.is(i+2, "<")) {
+				i = p.typeDecl(i, exported)
+				continue
+			}
+		case "enum":
+			if p.tok(i+1).Kind == 'i' && p.is(i+2, "{") {
+				name := p.tok(i + 1).Text
+				if p.topLevel() {
+					p.mod.AST.Types = append(p.mod.AST.Types, TypeInfo{Name: name, Type: "enum", Exported: exported})
+					if exported {
+						p.exports[name] = true
+					}
+				}
+				i = p.skipBalanced(i + 2)
+				continue
+			}
+		case "const", "let", "var":
+			if next, ok := p.varDecl(i, exported); ok {
+				i = next
+				continue
+			}
+		case "exports", "module":
+			// CommonJS: exports.name = function ... / module.exports.name = ...
+			j := i
+			if t.Text == "module" && p.is(i+1, ".") && p.is(i+2, "exports") {
+				j = i + 2
+			}
+			if p.tok(j).Text == "exports" && p.is(j+1, ".") && p.tok(j+2).Kind == 'i' && p.is(j+3, "=") {
+				name := p.tok(j + 2).Text
+				p.exports[name] = true
+				if next, ok := p.functionValue(i, j+4, name, true); ok {
+					i = next
+				}
+				continue
+			}
+		}
+
+		// Calls: name(...), obj.method(...), this.method(...), new Type(...).
+		if p.is(i+1, "(") && !jsNotCallees[t.Text] && !p.is(i-1, "function") {
+			parts := []string{t.Text}
+			for j := i - 1; (p.is(j, ".") || p.is(j, "?.")) && p.tok(j-1).Kind == 'i'; j -= 2 {
+				parts = append([]string{p.tok(j - 1).Text}, parts...)
+			}
+			_, class := p.owner()
+			switch {
+			case len(parts) == 2 && parts[0] == "this" && class != "":
+				p.addCall(class + "." + t.Text)
+			case len(parts) == 1:
+				name := t.Text
+				if orig, ok := p.aliases[name]; ok {
+					name = orig
+				}
+				p.addCall(name)
+				if p.is(i-1, "new") {
+					p.addCall(name + ".constructor")
+				}
+			default:
+				p.addCall(t.Text)
+			}
+		}
+	}
+}
+
+// importDecl parses an import declaration starting at i and returns the
+// index of its last token.
+func (p *jsParser) importDecl(i int) int {
+	j := i + 1
+	if p.is(j, "type") && !p.is(j+1, "from") && !p.is(j+1, ",") {
+		j++ // import type { T } from "..."
+	}
+	for ; j < len(p.toks); j++ {
+		t := p.toks[j]
+		switch {
+		case t.Kind == 's':
+			p.addImport(jsStringValue(t.Text))
+			return j
+		case p.is(j, ";"):
+			return j
+		case t.Kind == 'i' && p.is(j+1, "as") && p.tok(j+2).Kind == 'i':
+			// { orig as local }; "* as ns" has no identifier before "as".
+			p.aliases[p.tok(j+2).Text] = t.Text
+			j += 2
+		}
+	}
+	return j
+}
+
+// exportList parses "{ a, b as c } [from '...']" or "* [as ns] from '...'"
+// starting at i and returns the index of its last token.
+func (p *jsParser) exportList(i int) int {
+	j := i
+	if p.is(j, "{") {
+		end := p.skipBalanced(j)
+		for k := j + 1; k < end; k++ {
+			if p.tok(k).Kind != 'i' {
+				continue
+			}
+			name := p.tok(k).Text
+			if p.is(k+1, "as") && p.tok(k+2).Kind == 'i' {
+				name = p.tok(k + 2).Text
+				k += 2
+			}
+			if name != "type" {
+				p.exports[name] = true
+			}
+		}
+		j = end
+	} else if p.is(j+1, "as") && p.tok(j+2).Kind == 'i' {
+		p.exports[p.tok(j+2).Text] = true
+		j += 2
+	}
+	if p.is(j+1, "from") && p.tok(j+2).Kind == 's' {
+		p.addImport(jsStringValue(p.tok(j + 2).Text))
+		j += 2
+	}
+	return j
+}
+
+// declStart returns the index of the first token of the declaration whose
+// keyword is at i, including export, default, declare and async modifiers.
+func (p *jsParser) declStart(i int) int {
+	for i > 0 {
+		switch p.tok(i - 1).Text {
+		case "export", "default", "declare", "async":
+			if p.tok(i-1).Kind == 'i' {
+				i--
+				continue
+			}
+		}
+		break
+	}
+	return i
+}
+
+// skipExport returns the index of the first token at or after i that is not
+// an export, default or declare keyword.
+func (p *jsParser) skipExport(i int) int {
+	for p.is(i, "export") || p.is(i, "default") || p.is(i, "declare") {
+		i++
+	}
+	return i
+}
+
+// functionDecl parses "function [*] [name] (params) [: type] {" at i.
+func (p *jsParser) functionDecl(i int, exported bool) int {
+	first := p.declStart(i)
+	j := i + 1
+	if p.is(j, "*") {
+		j++
+	}
+	name := ""
+	if p.tok(j).Kind == 'i' {
+		name = p.tok(j).Text
+		j++
+	}
+	if p.is(j, "<") {
+		j = p.skipBalanced(j) + 1
+	}
+	if !p.is(j, "(") {
+		return i
+	}
+	body := p.bodyStart(p.skipBalanced(j) + 1)
+	if name == "" && p.is(i-1, "default") {
+		name = "default"
+	}
+	if name == "" || !p.topLevel() {
+		return j - 1
+	}
+	if exported {
+		p.exports[name] = true
+	}
+	fn := FuncInfo{Name: name, Signature: p.text(p.skipExport(first), body-1), Exported: exported}
+	def := p.addDef(name, fn, p.tok(first).Line, p.tok(first))
+	if p.is(body, "{") {
+		_, class := p.owner()
+		p.pending = &jsFrame{kind: 'f', class: class, def: def, owns: true}
+	}
+	return body - 1
+}
+
+// functionValue handles the value of "name = <value>" starting at j: a
+// function expression, an arrow function or a class expression. It returns
+// the index to continue after and whether the value was a function or class.
+func (p *jsParser) functionValue(first, j int, name string, exported bool) (int, bool) {
+	k := j
+	if p.is(k, "async") {
+		k++
+	}
+	switch {
+	case p.is(k, "function"):
+		if p.is(k+1, "*") {
+			k++
+		}
+		if p.tok(k+1).Kind == 'i' {
+			k++
+		}
+		if !p.is(k+1, "(") {
+			return j, false
+		}
+		body := p.bodyStart(p.skipBalanced(k+1) + 1)
+		if !p.topLevel() {
+			return k, true
+		}
+		fn := FuncInfo{Name: name, Signature: p.text(p.skipExport(first), body-1), Exported: exported}
+		def := p.addDef(name, fn, p.tok(first).Line, p.tok(first))
+		if p.is(body, "{") {
+			p.pending = &jsFrame{kind: 'f', def: def, owns: true}
+		}
+		return body - 1, true
+	case p.is(k, "class"):
+		return p.classDecl(k, name, exported), true
+	}
+
+	// Arrow functions: (params) [: type] => body, or ident => body.
+	if p.is(k, "<") {
+		k = p.skipBalanced(k) + 1
+	}
+	var arrow int
+	switch {
+	case p.is(k, "("):
+		arrow = p.bodyStart(p.skipBalanced(k) + 1)
+		if !p.is(arrow, "=>") {
+			return j, false
+		}
+	case p.tok(k).Kind == 'i' && p.is(k+1, "=>"):
+		arrow = k + 1
+	default:
+		return j, false
+	}
+	if !p.topLevel() {
+		return arrow, true
+	}
+	fn := FuncInfo{Name: name, Signature: p.text(p.skipExport(first), arrow), Exported: exported}
+	def := p.addDef(name, fn, p.tok(first).Line, p.tok(first))
+	if p.is(arrow+1, "{") {
+		p.pending = &jsFrame{kind: 'f', def: def, owns: true}
+	} else {
+		p.expr, p.exprFrames, p.exprParens = def, len(p.frames), p.parens
+	}
+	return arrow, true
+}
+
+// varDecl parses "const name [: type] = value" at i when value is a
+// function or class.
+func (p *jsParser) varDecl(i int, exported bool) (int, bool) {
+	if p.tok(i+1).Kind != 'i' {
+		return i, false
+	}
+	name := p.tok(i + 1).Text
+	j := i + 2
+	if p.is(j, ":") {
+		// Skip the type annotation up to the initializer.
+		for j < len(p.toks) && !p.is(j, "=") && !p.is(j, ";") {
+			if p.is(j, "(") || p.is(j, "[") || p.is(j, "{") || p.is(j, "<") {
+				j = p.skipBalanced(j)
+			}
+			j++
+		}
+	}
+	if !p.is(j, "=") {
+		return i, false
+	}
+	if exported {
+		p.exports[name] = true
+	}
+	return p.functionValue(p.declStart(i), j+1, name, exported)
+}
+
+// classDecl parses "class [Name] [<T>] [extends X] [implements A, B] {" at i.
+func (p *jsParser) classDecl(i int, name string, exported bool) int {
+	first := p.declStart(i)
+	j := i + 1
+	if p.tok(j).Kind == 'i' && p.tok(j).Text == name {
+		j++
+	}
+	if p.is(j, "<") {
+		j = p.skipBalanced(j) + 1
+	}
+	var bases []string
+	for j < len(p.toks) && !p.is(j, "{") {
+		switch {
+		case p.is(j, "extends"), p.is(j, "implements"), p.is(j, ","):
+			k := j + 1
+			for k < len(p.toks) && !p.is(k, "{") && !p.is(k, ",") && !p.is(k, "implements") {
+				if p.is(k, "(") || p.is(k, "<") {
+					k = p.skipBalanced(k)
+				}
+				k++
+			}
+			if base := p.text(j+1, k-1); base != "" {
+				bases = append(bases, base)
+			}
+			j = k
+		default:
+			j++
+		}
+	}
+	if name == "" && p.is(i-1, "default") {
+		name = "default"
+	}
+	_, class := p.owner()
+	if name == "" || !p.topLevel() {
+		p.pending = &jsFrame{kind: 'b', class: class, def: -1}
+		if def, _ := p.owner(); def >= 0 {
+			p.pending.def = def
+		}
+		return j - 1
+	}
+	if exported {
+		p.exports[name] = true
+	}
+	ci := ClassInfo{Name: name, Bases: bases, Exported: exported}
+	if p.withDocs {
+		ci.Doc = jsDocText(p.tok(first).Doc)
+	}
+	for _, b := range bases {
+		switch b {
+		case "Component", "PureComponent", "React.Component", "React.PureComponent":
+			p.components = append(p.components, name)
+		}
+	}
+	p.mod.AST.Classes = append(p.mod.AST.Classes, ci)
+	p.mod.Defs = append(p.mod.Defs, defSpan{Key: name, Kind: "class", Start: p.tok(first).Line, End: p.tok(first).Line})
+	p.pending = &jsFrame{kind: 'c', class: name, def: len(p.mod.Defs) - 1, owns: true}
+	return j - 1
+}
+
+// classMember parses a method or arrow-function property at the start of a
+// class member. It reports false for other members, which are scanned as
+// ordinary code.
+func (p *jsParser) classMember(i int, top jsFrame) (int, bool) {
+	first := i
+	j := i
+	// Decorators: @Name, @Name(...), @a.b(...).
+	var decorators []string
+	for p.is(j, "@") {
+		from := j + 1
+		j++
+		for p.tok(j).Kind == 'i' && p.is(j+1, ".") {
+			j += 2
+		}
+		if p.is(j+1, "(") {
+			j = p.skipBalanced(j + 1)
+		}
+		decorators = append(decorators, p.text(from, j))
+		j++
+	}
+	sigFrom := j
+	private := false
+	for p.tok(j).Kind == 'i' && jsMemberModifiers[p.tok(j).Text] && (p.tok(j+1).Kind == 'i' || p.is(j+1, "#") || p.is(j+1, "*") || p.is(j+1, "[")) {
+		if p.tok(j).Text == "private" {
+			private = true
+		}
+		j++
+	}
+	if p.is(j, "*") {
+		j++
+	}
+	if p.is(j, "#") {
+		private = true
+		j++
+	}
+	if p.tok(j).Kind != 'i' && p.tok(j).Kind != 's' {
+		return i, false
+	}
+	name := p.tok(j).Text
+	if p.tok(j).Kind == 's' {
+		name = jsStringValue(name)
+	}
+	j++
+	if p.is(j, "?") || p.is(j, "!") {
+		j++
+	}
+	if p.is(j, "<") {
+		j = p.skipBalanced(j) + 1
+	}
+
+	cls := &p.mod.AST.Classes[len(p.mod.AST.Classes)-1]
+	if top.class != cls.Name {
+		return i, false
+	}
+	key := top.class + "." + name
+	switch {
+	case p.is(j, "("):
+		body := p.bodyStart(p.skipBalanced(j) + 1)
+		sig := p.text(sigFrom, body-1)
+		cls.Methods = append(cls.Methods, sig)
+		fn := FuncInfo{Name: name, Receiver: top.class, Signature: sig, Decorators: decorators, Exported: cls.Exported && !private}
+		def := p.addDef(key, fn, p.tok(first).Line, p.tok(first))
+		if p.is(body, "{") {
+			p.pending = &jsFrame{kind: 'f', class: top.class, def: def, owns: true}
+			return body - 1, true
+		}
+		return body, true
+	case p.is(j, "=") || p.is(j, ":"):
+		k := j
+		if p.is(k, ":") {
+			for k < len(p.toks) && !p.is(k, "=") && !p.is(k, ";") && p.tok(k).Line == p.tok(j).Line {
+				if p.is(k, "(") || p.is(k, "<") || p.is(k, "{") {
+					k = p.skipBalanced(k)
+				}
+				k++
+			}
+			if !p.is(k, "=") {
+				return k - 1, true
+			}
+		}
+		// name = (args) => body
+		a := k + 1
+		if p.is(a, "async") {
+			a++
+		}
+		var arrow int
+		switch {
+		case p.is(a, "("):
+			arrow = p.bodyStart(p.skipBalanced(a) + 1)
+		case p.tok(a).Kind == 'i' && p.is(a+1, "=>"):
+			arrow = a + 1
+		}
+		if arrow == 0 || !p.is(arrow, "=>") {
+			return i, false
+		}
+		sig := p.text(sigFrom, arrow)
+		cls.Methods = append(cls.Methods, sig)
+		fn := FuncInfo{Name: name, Receiver: top.class, Signature: sig, Decorators: decorators, Exported: cls.Exported && !private}
+		def := p.addDef(key, fn, p.tok(first).Line, p.tok(first))
+		if p.is(arrow+1, "{") {
+			p.pending = &jsFrame{kind: 'f', class: top.class, def: def, owns: true}
+		} else {
+			p.expr, p.exprFrames, p.exprParens = def, len(p.frames), p.parens
+		}
+		return arrow, true
+	}
+	return i, false
+}
+
+// interfaceDecl parses "interface Name [<T>] [extends A, B] {" at i.
+func (p *jsParser) interfaceDecl(i int, exported bool) int {
+	first := p.declStart(i)
+	name := p.tok(i + 1).Text
+	j := i + 2
+	it := InterfaceInfo{Name: name, Exported: exported}
+	if p.is(j, "<") {
+		end := p.skipBalanced(j)
+		it.TypeParams = p.text(j, end)
+		j = end + 1
+	}
+	if p.is(j, "extends") {
+		k := j + 1
+		for k < len(p.toks) && !p.is(k, "{") {
+			from := k
+			for k < len(p.toks) && !p.is(k, "{") && !p.is(k, ",") {
+				if p.is(k, "<") {
+					k = p.skipBalanced(k)
+				}
+				k++
+			}
+			it.Embeds = append(it.Embeds, p.text(from, k-1))
+			if p.is(k, ",") {
+				k++
+			}
+		}
+		j = k
+	}
+	if p.withDocs {
+		it.Doc = jsDocText(p.tok(first).Doc)
+	}
+	if exported {
+		p.exports[name] = true
+	}
+	idx := -1
+	if p.topLevel() {
+		p.mod.AST.Interfaces = append(p.mod.AST.Interfaces, it)
+		idx = len(p.mod.AST.Interfaces) - 1
+	}
+	p.pending = &jsFrame{kind: 'i', def: -1, iface: idx}
+	return j - 1
+}
+
+// interfaceMember records one interface member starting at i and returns
+// the index of its last token.
+func (p *jsParser) interfaceMember(i int, top jsFrame) int {
+	j := i
+	for j < len(p.toks) {
+		if p.is(j, ";") || p.is(j, ",") || p.is(j, "}") {
+			break
+		}
+		if p.is(j, "(") || p.is(j, "[") || p.is(j, "{") || p.is(j, "<") {
+			j = p.skipBalanced(j)
+		}
+		if j+1 < len(p.toks) && p.tok(j+1).Line > p.tok(j).Line && p.stmtStart(j+1) {
+			j++
+			break
+		}
+		j++
+	}
+	if top.iface >= 0 {
+		if member := p.text(i, j-1); member != "" {
+			it := &p.mod.AST.Interfaces[top.iface]
+			it.Methods = append(it.Methods, member)
+		}
+	}
+	if p.is(j, "}") || (j < len(p.toks) && !p.is(j, ";") && !p.is(j, ",")) {
+		return j - 1
+	}
+	return j
+}
+
+// typeDecl parses "type Name [<T>] = ..." at i up to its end.
+func (p *jsParser) typeDecl(i int, exported bool) int {
+	name := p.tok(i + 1).Text
+	j := i + 2
+	t := TypeInfo{Name: name, Alias: true, Exported: exported}
+	if p.is(j, "<") {
+		end := p.skipBalanced(j)
+		t.TypeParams = p.text(j, end)
+		j = end + 1
+	}
+	if !p.is(j, "=") {
+		return i
+	}
+	k := j + 1
+	for k < len(p.toks) && !p.is(k, ";") {
+		if p.is(k, "(") || p.is(k, "[") || p.is(k, "{") || p.is(k, "<") {
+			k = p.skipBalanced(k)
+		}
+		next := p.tok(k + 1)
+		if k+1 < len(p.toks) && next.Line > p.tok(k).Line && !p.is(k+1, "|") && !p.is(k+1, "&") &&
+			!p.is(k, "|") && !p.is(k, "&") && !p.is(k, "=") && !p.is(k, "=>") && !p.is(k, ",") {
+			break
+		}
+		k++
+	}
+	if k >= len(p.toks) {
+		k = len(p.toks) - 1
+	}
+	last := k
+	if p.is(last, ";") {
+		last--
+	}
+	t.Type = p.text(j+1, last)
+	if p.withDocs {
+		t.Doc = jsDocText(p.tok(p.declStart(i)).Doc)
+	}
+	if exported {
+		p.exports[name] = true
+	}
+	if p.topLevel() {
+		p.mod.AST.Types = append(p.mod.AST.Types, t)
+	}
+	return k
+}
+
+// jsTokens splits JavaScript or TypeScript source into tokens, skipping
+// whitespace and comments. Template literal expressions are tokenized as
+// code so that calls inside them are seen.
+func jsTokens(s string) []jsToken {
+	var toks []jsToken
+	line, braces := 1, 0
+	var templates []int // brace depth at each open ${ expression
+	doc := ""
+	emit := func(kind byte, from, to int) {
+		toks = append(toks, jsToken{Kind: kind, Text: s[from:to], Line: line, Off: from, End: to, Doc: doc})
+		line += strings.Count(s[from:to], "\n")
+		doc = ""
+	}
+	regexOK := func() bool {
+		if len(toks) == 0 {
+			return true
+		}
+		t := toks[len(toks)-1]
+		switch t.Kind {
+		case 'p':
+			return t.Text != ")" && t.Text != "]" && t.Text != "}"
+		case 'i':
+			return jsRegexKeywords[t.Text]
+		}
+		return false
+	}
+	// template scans template text from i (just past ` or }) and returns
+	// the offset to continue lexing at.
+	template := func(from, i int) int {
+		for i < len(s) {
+			switch {
+			case s[i] == '\\':
+				i += 2
+				continue
+			case s[i] == '`':
+				emit('t', from, i+1)
+				return i + 1
+			case strings.HasPrefix(s[i:], "${"):
+				emit('t', from, i+2)
+				templates = append(templates, braces)
+				braces++
+				return i + 2
+			}
+			i++
+		}
+		emit('t', from, len(s))
+		return len(s)
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(s[i:], "//"):
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			doc = ""
+		case strings.HasPrefix(s[i:], "/*"):
+			end := strings.Index(s[i+2:], "*/")
+			j := len(s)
+			if end >= 0 {
+				j = i + 2 + end + 2
+			}
+			doc = ""
+			if strings.HasPrefix(s[i:], "/**") {
+				doc = s[i:j]
+			}
+			line += strings.Count(s[i:j], "\n")
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c && s[j] != '\n' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j < len(s) && s[j] == c {
+				j++
+			}
+			if j > len(s) {
+				j = len(s)
+			}
+			emit('s', i, j)
+			i = j
+		case c == '`':
+			i = template(i, i+1)
+		case c == '}' && len(templates) > 0 && braces-1 == templates[len(templates)-1]:
+			templates = templates[:len(templates)-1]
+			braces--
+			i = template(i, i+1)
+		case c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (isIdentByte(s[j]) || s[j] == '$' || s[j] >= 0x80) {
+				j++
+			}
+			emit('i', i, j)
+			i = j
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9':
+			j := i + 1
+			for j < len(s) && (isIdentByte(s[j]) || s[j] == '.') {
+				j++
+			}
+			emit('n', i, j)
+			i = j
+		case c == '/' && regexOK():
+			j, class := i+1, false
+			for j < len(s) && s[j] != '\n' {
+				if s[j] == '\\' {
+					j += 2
+					continue
+				}
+				if s[j] == '[' {
+					class = true
+				} else if s[j] == ']' {
+					class = false
+				} else if s[j] == '/' && !class {
+					j++
+					break
+				}
+				j++
+			}
+			for j < len(s) && isIdentByte(s[j]) {
+				j++
+			}
+			if j > len(s) {
+				j = len(s)
+			}
+			emit('r', i, j)
+			i = j
+		default:
+			n := 1
+			for _, op := range []string{"...", "=>", "?."} {
+				if strings.HasPrefix(s[i:], op) {
+					n = len(op)
+					break
+				}
+			}
+			switch c {
+			case '{':
+				braces++
+			case '}':
+				braces--
+			}
+			emit('p', i, i+n)
+			i += n
+		}
+	}
+	return toks
+}
+
+// jsStringValue strips the quotes of a string literal.
+func jsStringValue(lit string) string {
+	if len(lit) >= 2 {
+		return lit[1 : len(lit)-1]
+	}
+	return lit
+}
+
+// jsDocText returns the text of a JSDoc comment without its delimiters and
+// leading asterisks.
+func jsDocText(doc string) string {
+	if doc == "" {
+		return ""
+	}
+	doc = strings.TrimSuffix(strings.TrimPrefix(doc, "/**"), "*/")
+	lines := strings.Split(doc, "\n")
+	for i, ln := range lines {
+		ln = strings.TrimSpace(ln)
+		lines[i] = strings.TrimSpace(strings.TrimPrefix(ln, "*"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// resolveJSImport resolves a relative import specifier in the file relPath
+// to a project file, trying the usual extensions and index files; a ".js"
+// specifier may also name a TypeScript source. Bare specifiers and
+// specifiers that do not resolve are returned unchanged.
+func resolveJSImport(projectPath, relPath, spec string) string {
+	if !strings.HasPrefix(spec, "./") && !strings.HasPrefix(spec, "../") {
+		return spec
+	}
+	base := path.Join(path.Dir(filepath.ToSlash(relPath)), spec)
+	candidates := []string{base}
+	trimmed := base
+	switch path.Ext(base) {
+	case ".js", ".jsx", ".mjs", ".cjs":
+		trimmed = strings.TrimSuffix(base, path.Ext(base))
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, trimmed+ext)
+	}
+	for _, ext := range jsExtensions {
+		candidates = append(candidates, base+"/index"+ext)
+	}
+	for _, c := range candidates {
+		if info, err := os.Stat(filepath.Join(projectPath, filepath.FromSlash(c))); err == nil && !info.IsDir() {
+			return c
+		}
+	}
+	return spec
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// languageFilenames maps file names that carry no meaningful extension to
+// their language.
+var languageFilenames = map[string]string{
+	"Dockerfile":     "dockerfile",
+	"Containerfile":  "dockerfile",
+	"Makefile":       "makefile",
+	"makefile":       "makefile",
+	"GNUmakefile":    "makefile",
+	"CMakeLists.txt": "cmake",
+	"go.mod":         "gomod",
+	"go.work":        "gomod",
+	"go.sum":         "gosum",
+	"Gemfile":        "ruby",
+	"Rakefile":       "ruby",
+	"Vagrantfile":    "ruby",
+	"Jenkinsfile":    "groovy",
+	"BUILD":          "starlark",
+	"BUILD.bazel":    "starlark",
+	"WORKSPACE":      "starlark",
+	"Pipfile":        "toml",
+	".bashrc":        "bash",
+	".bash_profile":  "bash",
+	".zshrc":         "zsh",
+	".profile":       "shell",
+}
+
+// languagePrefixes maps the prefixes of variant file names, such as
+// Dockerfile.dev, to their language.
+var languagePrefixes = map[string]string{
+	"Dockerfile.": "dockerfile",
+	"Makefile.":   "makefile",
+}
+
+// shebangInterpreters maps the interpreter named by a "#!" line, with any
+// version suffix removed, to the language of the script.
+var shebangInterpreters = map[string]string{
+	"python":  "python",
+	"node":    "javascript",
+	"nodejs":  "javascript",
+	"deno":    "typescript",
+	"bun":     "javascript",
+	"bash":    "bash",
+	"sh":      "shell",
+	"dash":    "shell",
+	"ash":     "shell",
+	"ksh":     "shell",
+	"zsh":     "zsh",
+	"ruby":    "ruby",
+	"perl":    "perl",
+	"php":     "php",
+	"lua":     "lua",
+	"Rscript": "r",
+	"pwsh":    "powershell",
+}
+
+// cppExtensions are the extensions of C++ sources. A project containing any
+// of them is a C++ project, in which ".h" headers are C++ too.
+var cppExtensions = map[string]bool{
+	".cpp": true, ".cc": true, ".cxx": true, ".c++": true,
+	".hpp": true, ".hh": true, ".hxx": true, ".h++": true,
+}
+
+// detectLanguage returns the language of the file at relPath with contents
+// src. The project's language overrides are tried first, then well-known
+// file names, the extension and, for files still unknown, a "#!" line.
+// Unknown files are "plaintext".
+func detectLanguage(relPath string, src []byte, cfg *Config) string {
+	if lang := languageOverride(relPath, cfg.Languages); lang != "" {
+		return lang
+	}
+	base := filepath.Base(relPath)
+	if lang := languageFilenames[base]; lang != "" {
+		return lang
+	}
+	for prefix, lang := range languagePrefixes {
+		if strings.HasPrefix(base, prefix) {
+			return lang
+		}
+	}
+	ext := strings.ToLower(filepath.Ext(base))
+	if ext == ".h" && cfg.cppProject {
+		return "cpp"
+	}
+	if lang := languageMap[ext]; lang != "" {
+		return lang
+	}
+	if lang := shebangLanguage(src); lang != "" {
+		return lang
+	}
+	return "plaintext"
+}
+
+// languageOverride returns the language assigned to relPath by the first
+// matching pattern of overrides, in lexical order. Patterns match the
+// project-relative path or the file name.
+func languageOverride(relPath string, overrides map[string]string) string {
+	if len(overrides) == 0 {
+		return ""
+	}
+	patterns := make([]string, 0, len(overrides))
+	for pat := range overrides {
+		patterns = append(patterns, pat)
+	}
+	sort.Strings(patterns)
+	for _, pat := range patterns {
+		if matchesAnyGlob(filepath.ToSlash(relPath), []string{pat}) {
+			return overrides[pat]
+		}
+	}
+	return ""
+}
+
+// shebangLanguage returns the language of a script from its "#!" line, as
+// in "#!/usr/bin/env python3" or "#!/bin/sh -e", or "" if there is none or
+// the interpreter is unknown.
+func shebangLanguage(src []byte) string {
+	if !bytes.HasPrefix(src, []byte("#!")) {
+		return ""
+	}
+	line, _, _ := bytes.Cut(src[2:], []byte("\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := filepath.Base(fields[0])
+	if interp == "env" {
+		// Skip env's options and variable assignments.
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = f
+				break
+			}
+		}
+	}
+	return shebangInterpreters[strings.TrimRight(interp, "0123456789.")]
+}
+
+// hasCppSources reports whether any of paths is a C++ source file.
+func hasCppSources(paths []string) bool {
+	for _, p := range paths {
+		if cppExtensions[strings.ToLower(filepath.Ext(p))] {
+			return true
+		}
+	}
+	return false
+}
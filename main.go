@@ -1,17 +1,22 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"go/ast"
 	"go/parser"
+	"go/printer"
 	"go/token"
-	"io"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -22,51 +27,239 @@ import (
 	"github.com/spf13/cobra"
 )
 
-const version = "1.0.0-contextify"
+// version is overridden at build time via -ldflags "-X main.version=...".
+var version = "1.0.0-contextify"
+
+// tokenizerName identifies the heuristic used for token estimates.
+const tokenizerName = "chars/4"
 
 // Config holds extraction configuration read from flags or .ai-context.yaml.
 // Fields map to CLI flags and to the YAML config file.
 type Config struct {
-	Path          string   `json:"path" yaml:"path"`
-	Output        string   `json:"output" yaml:"output"`
-	Format        string   `json:"format" yaml:"format"`
-	Exclude       []string `json:"exclude" yaml:"exclude"`
-	Include       []string `json:"include" yaml:"include"`
-	StripComments bool     `json:"strip_comments" yaml:"strip_comments"`
-	MaxTokens     int      `json:"max_tokens" yaml:"max_tokens"`
-	AST           bool     `json:"ast" yaml:"ast"`
-	Focus         string   `json:"focus" yaml:"focus"`
-	Depth         int      `json:"depth" yaml:"depth"`
-	Workers       int      `json:"workers" yaml:"workers"`
+	Path           string   `json:"path" yaml:"path"`
+	Output         string   `json:"output,omitempty" yaml:"output,omitempty"`
+	Format         string   `json:"format" yaml:"format"`
+	Exclude        []string `json:"exclude" yaml:"exclude"`
+	Include        []string `json:"include" yaml:"include"`
+	StripComments  bool     `json:"strip_comments" yaml:"strip_comments"`
+	StripPolicy    string   `json:"strip_policy" yaml:"strip_policy"`
+	MaxTokens      int      `json:"max_tokens" yaml:"max_tokens"`
+	AST            bool     `json:"ast" yaml:"ast"`
+	Focus          string   `json:"focus" yaml:"focus"`
+	Depth          int      `json:"depth" yaml:"depth"`
+	Workers        int      `json:"workers" yaml:"workers"`
+	Stream         bool     `json:"stream" yaml:"stream"`
+	LineNumbers    bool     `json:"line_numbers" yaml:"line_numbers"`
+	Reproducible   bool     `json:"reproducible" yaml:"reproducible"`
+	Skeleton       bool     `json:"skeleton" yaml:"skeleton"`
+	SkeletonGlobs  []string `json:"skeleton_globs" yaml:"skeleton_globs"`
+	Docs           bool     `json:"docs" yaml:"docs"`
+	Graph          bool     `json:"graph" yaml:"graph"`
+	GraphFormat    string   `json:"graph_format" yaml:"graph_format"`
+	GraphExternal  bool     `json:"graph_external" yaml:"graph_external"`
+	WithTests      bool     `json:"with_tests" yaml:"with_tests"`
+	TestWeight     int      `json:"test_weight" yaml:"test_weight"`
+	GOOS           string   `json:"goos" yaml:"goos"`
+	GOARCH         string   `json:"goarch" yaml:"goarch"`
+	Tags           []string `json:"tags" yaml:"tags"`
+	PlatformPolicy string   `json:"platform_policy" yaml:"platform_policy"`
+	Generated      string   `json:"generated" yaml:"generated"`
+	// Languages assigns languages to the files matching glob patterns,
+	// overriding detection.
+	Languages map[string]string `json:"languages" yaml:"languages"`
+	// Whitespace sets how blank lines are compacted per language, or for
+	// "*" all others: keep, collapse or remove.
+	Whitespace map[string]string `json:"whitespace" yaml:"whitespace"`
+	// StripDocstrings removes Python docstrings, which are string literals
+	// rather than comments.
+	StripDocstrings bool `json:"strip_docstrings" yaml:"strip_docstrings"`
+	// NotebookOutputs sets whether the cell outputs of Jupyter notebooks are
+	// dropped or kept truncated.
+	NotebookOutputs string `json:"notebook_outputs" yaml:"notebook_outputs"`
+
+	// cppProject is set when the project has C++ sources, making ".h"
+	// headers C++.
+	cppProject bool
+	// selfExclude are the exclude patterns added for the bundles of the
+	// running executable, which depend on its name.
+	selfExclude []string
 }
 
 // FileInfo represents the extracted metadata and (optionally) content for one file.
 type FileInfo struct {
-	Path     string   `json:"path" yaml:"path"`
-	Language string   `json:"language" yaml:"language"`
-	Content  string   `json:"content" yaml:"content"`
-	Size     int64    `json:"size" yaml:"size"`
-	AST      *ASTInfo `json:"ast,omitempty" yaml:"ast,omitempty"`
-	Weight   int      `json:"-" yaml:"-"`
+	Path     string `json:"path" yaml:"path"`
+	Language string `json:"language" yaml:"language"`
+	Content  string `json:"content" yaml:"content"`
+	Size     int64  `json:"size" yaml:"size"`
+	SHA256   string `json:"sha256" yaml:"sha256"`
+	Skeleton bool   `json:"skeleton,omitempty" yaml:"skeleton,omitempty"`
+	// Generated marks files carrying a "Code generated ... DO NOT EDIT." header.
+	Generated bool        `json:"generated,omitempty" yaml:"generated,omitempty"`
+	Ranges    []LineRange `json:"ranges,omitempty" yaml:"ranges,omitempty"`
+	AST       *ASTInfo    `json:"ast,omitempty" yaml:"ast,omitempty"`
+	Weight    int         `json:"-" yaml:"-"`
+	// ContentLen is the length of the rendered content. It stays valid after
+	// Content is dropped in streaming mode and drives token estimates.
+	ContentLen int `json:"-" yaml:"-"`
+}
+
+// bundledSize returns the number of bytes of the file in the bundle: its
+// size, or the length of its content if it is cut to line ranges.
+func (f *FileInfo) bundledSize() int64 {
+	if len(f.Ranges) > 0 {
+		return int64(f.ContentLen)
+	}
+	return f.Size
+}
+
+// LineRange is an inclusive range of 1-based line numbers. Files that carry
+// ranges contain only those lines of the original source.
+type LineRange struct {
+	Start int `json:"start" yaml:"start"`
+	End   int `json:"end" yaml:"end"`
 }
 
-// ASTInfo is a lightweight summary of a Go file's top-level AST details.
+// ASTInfo is a lightweight summary of a source file's top-level declarations.
+// For Python files Package holds the dotted module name and classes are
+// listed under Classes. JavaScript and TypeScript files leave Package empty
+// and list their exports and React components.
 type ASTInfo struct {
-	Package   string   `json:"package" yaml:"package"`
-	Imports   []string `json:"imports" yaml:"imports"`
-	Structs   []string `json:"structs" yaml:"structs"`
-	Functions []string `json:"functions" yaml:"functions"`
+	Package    string          `json:"package" yaml:"package"`
+	Doc        string          `json:"doc,omitempty" yaml:"doc,omitempty"`
+	Imports    []string        `json:"imports" yaml:"imports"`
+	Modules    []string        `json:"modules,omitempty" yaml:"modules,omitempty"`
+	Exports    []string        `json:"exports,omitempty" yaml:"exports,omitempty"`
+	Structs    []StructInfo    `json:"structs" yaml:"structs"`
+	Classes    []ClassInfo     `json:"classes,omitempty" yaml:"classes,omitempty"`
+	Interfaces []InterfaceInfo `json:"interfaces,omitempty" yaml:"interfaces,omitempty"`
+	Impls      []ImplInfo      `json:"impls,omitempty" yaml:"impls,omitempty"`
+	Types      []TypeInfo      `json:"types,omitempty" yaml:"types,omitempty"`
+	Consts     []ValueInfo     `json:"consts,omitempty" yaml:"consts,omitempty"`
+	Vars       []ValueInfo     `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Functions  []FuncInfo      `json:"functions" yaml:"functions"`
+	Components []string        `json:"components,omitempty" yaml:"components,omitempty"`
+}
+
+// StructInfo describes a struct type declaration, its fields and the
+// methods declared on it anywhere in the same package.
+type StructInfo struct {
+	Name       string      `json:"name" yaml:"name"`
+	TypeParams string      `json:"type_params,omitempty" yaml:"type_params,omitempty"`
+	Doc        string      `json:"doc,omitempty" yaml:"doc,omitempty"`
+	Fields     []FieldInfo `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Methods    []string    `json:"methods,omitempty" yaml:"methods,omitempty"`
+	Exported   bool        `json:"exported" yaml:"exported"`
+}
+
+// ClassInfo describes a class declaration with its bases, decorators and
+// the signatures of the methods defined in its body. Kind is set for
+// class-like declarations other than classes, such as Java enums and
+// records or C structs; Fields lists member variable declarations.
+type ClassInfo struct {
+	Name       string   `json:"name" yaml:"name"`
+	Kind       string   `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Bases      []string `json:"bases,omitempty" yaml:"bases,omitempty"`
+	Decorators []string `json:"decorators,omitempty" yaml:"decorators,omitempty"`
+	Doc        string   `json:"doc,omitempty" yaml:"doc,omitempty"`
+	Fields     []string `json:"fields,omitempty" yaml:"fields,omitempty"`
+	Methods    []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+	Exported   bool     `json:"exported" yaml:"exported"`
+}
+
+// ImplInfo describes a Rust impl block: the type it is for, the trait it
+// implements, if any, and the signatures of the functions it defines.
+type ImplInfo struct {
+	TypeParams string   `json:"type_params,omitempty" yaml:"type_params,omitempty"`
+	Trait      string   `json:"trait,omitempty" yaml:"trait,omitempty"`
+	Type       string   `json:"type" yaml:"type"`
+	Methods    []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+}
+
+// FieldInfo describes a struct field. Embedded fields are named after their type.
+type FieldInfo struct {
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type" yaml:"type"`
+	Tag      string `json:"tag,omitempty" yaml:"tag,omitempty"`
+	Embedded bool   `json:"embedded,omitempty" yaml:"embedded,omitempty"`
+	Exported bool   `json:"exported" yaml:"exported"`
+}
+
+// InterfaceInfo describes an interface type and its method set. Embedded
+// interfaces and type-set constraints are listed separately.
+type InterfaceInfo struct {
+	Name       string   `json:"name" yaml:"name"`
+	TypeParams string   `json:"type_params,omitempty" yaml:"type_params,omitempty"`
+	Doc        string   `json:"doc,omitempty" yaml:"doc,omitempty"`
+	Methods    []string `json:"methods,omitempty" yaml:"methods,omitempty"`
+	Embeds     []string `json:"embeds,omitempty" yaml:"embeds,omitempty"`
+	Exported   bool     `json:"exported" yaml:"exported"`
+}
+
+// TypeInfo describes a named type or alias that is neither a struct nor an interface.
+type TypeInfo struct {
+	Name       string `json:"name" yaml:"name"`
+	TypeParams string `json:"type_params,omitempty" yaml:"type_params,omitempty"`
+	Doc        string `json:"doc,omitempty" yaml:"doc,omitempty"`
+	Type       string `json:"type" yaml:"type"`
+	Alias      bool   `json:"alias,omitempty" yaml:"alias,omitempty"`
+	Exported   bool   `json:"exported" yaml:"exported"`
+}
+
+// ValueInfo describes a package-level const or var.
+type ValueInfo struct {
+	Name     string `json:"name" yaml:"name"`
+	Type     string `json:"type,omitempty" yaml:"type,omitempty"`
+	Value    string `json:"value,omitempty" yaml:"value,omitempty"`
+	Exported bool   `json:"exported" yaml:"exported"`
+}
+
+// FuncInfo describes a function or method with its full signature,
+// including receiver and type parameters.
+type FuncInfo struct {
+	Name       string   `json:"name" yaml:"name"`
+	Receiver   string   `json:"receiver,omitempty" yaml:"receiver,omitempty"`
+	Signature  string   `json:"signature" yaml:"signature"`
+	Decorators []string `json:"decorators,omitempty" yaml:"decorators,omitempty"`
+	Doc        string   `json:"doc,omitempty" yaml:"doc,omitempty"`
+	Exported   bool     `json:"exported" yaml:"exported"`
+}
+
+// Metadata records how a bundle was produced so that it can be traced back
+// to a source tree and regenerated later.
+type Metadata struct {
+	Version   string   `json:"version" yaml:"version"`
+	Config    Config   `json:"config" yaml:"config"`
+	Git       *GitInfo `json:"git,omitempty" yaml:"git,omitempty"`
+	Tokenizer string   `json:"tokenizer" yaml:"tokenizer"`
+}
+
+// GitInfo describes the git state of the project when the bundle was produced.
+type GitInfo struct {
+	Commit string `json:"commit" yaml:"commit"`
+	Dirty  bool   `json:"dirty" yaml:"dirty"`
+}
+
+// PackageInfo is one entry of the project-wide Go package index.
+type PackageInfo struct {
+	ImportPath string   `json:"import_path" yaml:"import_path"`
+	Name       string   `json:"name" yaml:"name"`
+	Files      []string `json:"files" yaml:"files"`
+	Exported   []string `json:"exported,omitempty" yaml:"exported,omitempty"`
+	Imports    []string `json:"imports,omitempty" yaml:"imports,omitempty"`
 }
 
 // Context is the full project extraction result to be serialized.
 type Context struct {
-	ProjectPath     string     `json:"project_path" yaml:"project_path"`
-	TreeStructure   string     `json:"tree_structure" yaml:"tree_structure"`
-	Files           []FileInfo `json:"files" yaml:"files"`
-	TotalFiles      int        `json:"total_files" yaml:"total_files"`
-	TotalSize       int64      `json:"total_size" yaml:"total_size"`
-	EstimatedTokens int        `json:"estimated_tokens" yaml:"estimated_tokens"`
-	Truncated       bool       `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+	ProjectPath     string        `json:"project_path" yaml:"project_path"`
+	Metadata        *Metadata     `json:"metadata,omitempty" yaml:"metadata,omitempty"`
+	Packages        []PackageInfo `json:"packages,omitempty" yaml:"packages,omitempty"`
+	Graph           *GraphInfo    `json:"graph,omitempty" yaml:"graph,omitempty"`
+	TreeStructure   string        `json:"tree_structure" yaml:"tree_structure"`
+	Files           []FileInfo    `json:"files" yaml:"files"`
+	TotalFiles      int           `json:"total_files" yaml:"total_files"`
+	TotalSize       int64         `json:"total_size" yaml:"total_size"`
+	EstimatedTokens int           `json:"estimated_tokens" yaml:"estimated_tokens"`
+	Truncated       bool          `json:"truncated,omitempty" yaml:"truncated,omitempty"`
 }
 
 // defaultIgnorePatterns are common directory/file patterns that should be skipped.
@@ -87,41 +280,69 @@ var defaultIgnorePatterns = []string{
 // languageMap maps file extensions to a short language identifier.
 // Used to group output and to apply language-specific logic (e.g. comment stripping).
 var languageMap = map[string]string{
-	".go":    "go",
-	".java":  "java",
-	".py":    "python",
-	".js":    "javascript",
-	".ts":    "typescript",
-	".rs":    "rust",
-	".c":     "c",
-	".cpp":   "cpp",
-	".h":     "c",
-	".hpp":   "cpp",
-	".cs":    "csharp",
-	".rb":    "ruby",
-	".php":   "php",
-	".swift": "swift",
-	".kt":    "kotlin",
-	".scala": "scala",
-	".r":     "r",
-	".m":     "matlab",
-	".sh":    "shell",
-	".bash":  "bash",
-	".zsh":   "zsh",
-	".ps1":   "powershell",
-	".yaml":  "yaml",
-	".yml":   "yaml",
-	".json":  "json",
-	".xml":   "xml",
-	".html":  "html",
-	".css":   "css",
-	".scss":  "scss",
-	".sass":  "sass",
-	".less":  "less",
-	".sql":   "sql",
-	".md":    "markdown",
-	".rst":   "restructuredtext",
-	".tex":   "latex",
+	".go":         "go",
+	".java":       "java",
+	".py":         "python",
+	".js":         "javascript",
+	".ts":         "typescript",
+	".tsx":        "tsx",
+	".jsx":        "jsx",
+	".mjs":        "javascript",
+	".cjs":        "javascript",
+	".rs":         "rust",
+	".c":          "c",
+	".cpp":        "cpp",
+	".h":          "c",
+	".hpp":        "cpp",
+	".cs":         "csharp",
+	".rb":         "ruby",
+	".php":        "php",
+	".swift":      "swift",
+	".kt":         "kotlin",
+	".scala":      "scala",
+	".r":          "r",
+	".m":          "matlab",
+	".sh":         "shell",
+	".bash":       "bash",
+	".zsh":        "zsh",
+	".ps1":        "powershell",
+	".yaml":       "yaml",
+	".yml":        "yaml",
+	".json":       "json",
+	".xml":        "xml",
+	".html":       "html",
+	".css":        "css",
+	".scss":       "scss",
+	".sass":       "sass",
+	".less":       "less",
+	".sql":        "sql",
+	".proto":      "protobuf",
+	".cc":         "cpp",
+	".cxx":        "cpp",
+	".hh":         "cpp",
+	".hxx":        "cpp",
+	".pyi":        "python",
+	".mts":        "typescript",
+	".cts":        "typescript",
+	".kts":        "kotlin",
+	".tf":         "terraform",
+	".tfvars":     "terraform",
+	".hcl":        "hcl",
+	".vue":        "vue",
+	".svelte":     "svelte",
+	".toml":       "toml",
+	".ini":        "ini",
+	".mk":         "makefile",
+	".cmake":      "cmake",
+	".dockerfile": "dockerfile",
+	".gradle":     "groovy",
+	".groovy":     "groovy",
+	".bzl":        "starlark",
+	".pl":         "perl",
+	".lua":        "lua",
+	".md":         "markdown",
+	".rst":        "restructuredtext",
+	".tex":        "latex",
 }
 
 var rootCmd = &cobra.Command{
@@ -138,32 +359,74 @@ var extractCmd = &cobra.Command{
 }
 
 var (
-	cfgPath          string
-	cfgOutput        string
-	cfgFormat        string
-	cfgExclude       []string
-	cfgInclude       []string
-	cfgStripComments bool
-	cfgMaxTokens     int
-	cfgAST           bool
-	cfgFocus         string
-	cfgDepth         int
-	cfgWorkers       int
+	cfgPath            string
+	cfgOutput          string
+	cfgFormat          string
+	cfgExclude         []string
+	cfgInclude         []string
+	cfgStripComments   bool
+	cfgStripPolicy     string
+	cfgStripDocstrings bool
+	cfgNotebookOutputs string
+	cfgMaxTokens       int
+	cfgAST             bool
+	cfgFocus           string
+	cfgDepth           int
+	cfgWorkers         int
+	cfgStream          bool
+	cfgLineNumbers     bool
+	cfgReproducible    bool
+	cfgSkeleton        bool
+	cfgSkeletonGlobs   []string
+	cfgDocs            bool
+	cfgGraph           bool
+	cfgGraphFormat     string
+	cfgGraphExternal   bool
+	cfgWithTests       bool
+	cfgTestWeight      int
+	cfgGOOS            string
+	cfgGOARCH          string
+	cfgTags            []string
+	cfgPlatformPolicy  string
+	cfgGenerated       string
+	cfgLanguages       map[string]string
+	cfgWhitespace      map[string]string
 )
 
 func init() {
 	// CLI flags with sensible defaults.
 	extractCmd.Flags().StringVarP(&cfgPath, "path", "p", ".", "Path to the project directory")
 	extractCmd.Flags().StringVarP(&cfgOutput, "output", "o", "", "Output file path (default: auto-generated in project dir)")
-	extractCmd.Flags().StringVarP(&cfgFormat, "format", "f", "markdown", "Output format (markdown, json, yaml)")
+	extractCmd.Flags().StringVarP(&cfgFormat, "format", "f", "markdown", "Output format (markdown, json, yaml, jsonl)")
 	extractCmd.Flags().StringSliceVarP(&cfgExclude, "exclude", "e", []string{}, "Patterns to exclude (glob)")
 	extractCmd.Flags().StringSliceVarP(&cfgInclude, "include", "i", []string{}, "Patterns to include (glob)")
 	extractCmd.Flags().BoolVar(&cfgStripComments, "strip-comments", false, "Strip comments from code")
+	extractCmd.Flags().BoolVar(&cfgStripDocstrings, "strip-docstrings", false, "Strip Python docstrings from code")
+	extractCmd.Flags().StringVar(&cfgNotebookOutputs, "notebook-outputs", "", "Handling of Jupyter notebook cell outputs (drop, truncate; default: drop)")
+	extractCmd.Flags().StringVar(&cfgStripPolicy, "strip-policy", "", "Comments removed by --strip-comments (all, non-doc, keep-directives, license-only; default: keep-directives, or non-doc with --docs)")
 	extractCmd.Flags().IntVar(&cfgMaxTokens, "max-tokens", 0, "Maximum tokens (0 for unlimited)")
-	extractCmd.Flags().BoolVar(&cfgAST, "ast", false, "Enable AST extraction for Go files")
+	extractCmd.Flags().BoolVar(&cfgAST, "ast", false, "Enable AST summaries for supported source files")
 	extractCmd.Flags().StringVar(&cfgFocus, "focus", "", "Focus symbol (e.g. FuncName or Type.Method) for definition tracing")
 	extractCmd.Flags().IntVar(&cfgDepth, "depth", 1, "Depth for focus tracing (default 1)")
 	extractCmd.Flags().IntVar(&cfgWorkers, "workers", 4, "Number of concurrent workers for file processing")
+	extractCmd.Flags().BoolVar(&cfgStream, "stream", false, "Stream file contents to the output instead of holding them in memory")
+	extractCmd.Flags().BoolVar(&cfgLineNumbers, "line-numbers", false, "Prefix emitted content with original line numbers")
+	extractCmd.Flags().BoolVar(&cfgReproducible, "reproducible", false, "Produce byte-identical output for identical inputs (no timestamps)")
+	extractCmd.Flags().BoolVar(&cfgSkeleton, "skeleton", false, "Reduce Go, Rust, Java and C/C++ files to signatures and declarations, eliding function bodies")
+	extractCmd.Flags().StringSliceVar(&cfgSkeletonGlobs, "skeleton-glob", []string{}, "Patterns of files to reduce to skeletons (glob)")
+	extractCmd.Flags().BoolVar(&cfgDocs, "docs", false, "Capture Go doc comments and Python docstrings in AST summaries and keep doc comments when stripping comments")
+	extractCmd.Flags().BoolVar(&cfgGraph, "graph", false, "Include the package dependency graph and focused call graph (implies --ast)")
+	extractCmd.Flags().StringVar(&cfgGraphFormat, "graph-format", "mermaid", "Graph format (mermaid, dot)")
+	extractCmd.Flags().BoolVar(&cfgGraphExternal, "graph-external", false, "Include external modules in the dependency graph, collapsed to module roots")
+	extractCmd.Flags().BoolVar(&cfgWithTests, "with-tests", false, "Include Go tests of focused functions, even if test files are excluded")
+	extractCmd.Flags().IntVar(&cfgTestWeight, "test-weight", 400, "Trimming weight of tests paired with focused functions")
+	extractCmd.Flags().StringVar(&cfgGOOS, "goos", "", "Target GOOS for Go file selection (default: host when --goarch or --tags is set)")
+	extractCmd.Flags().StringVar(&cfgGOARCH, "goarch", "", "Target GOARCH for Go file selection (default: host when --goos or --tags is set)")
+	extractCmd.Flags().StringSliceVar(&cfgTags, "tags", []string{}, "Build tags satisfied for Go file selection")
+	extractCmd.Flags().StringVar(&cfgPlatformPolicy, "platform-policy", "exclude", "Handling of Go files not built for the target (exclude, down-weight)")
+	extractCmd.Flags().StringVar(&cfgGenerated, "generated", "down-weight", "Handling of generated files (keep, exclude, skeleton, down-weight)")
+	extractCmd.Flags().StringToStringVar(&cfgLanguages, "language", map[string]string{}, "Assign languages to files matching glob patterns, e.g. '*.h=cpp' (overrides detection)")
+	extractCmd.Flags().StringToStringVar(&cfgWhitespace, "whitespace", map[string]string{}, "Blank line compaction per language or '*', e.g. 'python=collapse' (keep, collapse, remove; default: remove when stripping comments, collapse for Python, Markdown and YAML)")
 
 	rootCmd.AddCommand(extractCmd)
 }
@@ -178,17 +441,38 @@ func main() {
 // runExtract composes the configuration, reads optional .ai-context.yaml, and runs extraction.
 func runExtract(cmd *cobra.Command, args []string) error {
 	cfg := &Config{
-		Path:          cfgPath,
-		Output:        cfgOutput,
-		Format:        cfgFormat,
-		Exclude:       append([]string{}, defaultIgnorePatterns...),
-		Include:       cfgInclude,
-		StripComments: cfgStripComments,
-		MaxTokens:     cfgMaxTokens,
-		AST:           cfgAST,
-		Focus:         cfgFocus,
-		Depth:         cfgDepth,
-		Workers:       cfgWorkers,
+		Path:            cfgPath,
+		Output:          cfgOutput,
+		Format:          cfgFormat,
+		Exclude:         append([]string{}, defaultIgnorePatterns...),
+		Include:         cfgInclude,
+		StripComments:   cfgStripComments,
+		StripPolicy:     cfgStripPolicy,
+		StripDocstrings: cfgStripDocstrings,
+		NotebookOutputs: cfgNotebookOutputs,
+		MaxTokens:       cfgMaxTokens,
+		AST:             cfgAST,
+		Focus:           cfgFocus,
+		Depth:           cfgDepth,
+		Workers:         cfgWorkers,
+		Stream:          cfgStream,
+		LineNumbers:     cfgLineNumbers,
+		Reproducible:    cfgReproducible,
+		Skeleton:        cfgSkeleton,
+		SkeletonGlobs:   cfgSkeletonGlobs,
+		Docs:            cfgDocs,
+		Graph:           cfgGraph,
+		GraphFormat:     cfgGraphFormat,
+		GraphExternal:   cfgGraphExternal,
+		WithTests:       cfgWithTests,
+		TestWeight:      cfgTestWeight,
+		GOOS:            cfgGOOS,
+		GOARCH:          cfgGOARCH,
+		Tags:            cfgTags,
+		PlatformPolicy:  cfgPlatformPolicy,
+		Generated:       cfgGenerated,
+		Languages:       cfgLanguages,
+		Whitespace:      cfgWhitespace,
 	}
 
 	// Merge user-specified exclude patterns after defaults.
@@ -198,7 +482,7 @@ func runExtract(cmd *cobra.Command, args []string) error {
 
 	// Load project-level config if present.
 	if configFile := filepath.Join(cfg.Path, ".ai-context.yaml"); fileExists(configFile) {
-		if err := loadConfigFile(configFile, cfg); err != nil {
+		if err := loadConfigFile(configFile, cfg, cmd.Flags().Changed); err != nil {
 			fmt.Fprintf(os.Stderr, "Warning: failed to load config file: %v\n", err)
 		}
 	}
@@ -209,16 +493,19 @@ func runExtract(cmd *cobra.Command, args []string) error {
 		exeNoExt := strings.TrimSuffix(exeBase, filepath.Ext(exeBase)) // e.g. "contextify"
 
 		// Append a few likely variants to the exclude list.
-		cfg.Exclude = appendUnique(cfg.Exclude, exeBase)
+		patterns := []string{exeBase}
 		if exeNoExt != exeBase {
-			cfg.Exclude = appendUnique(cfg.Exclude, exeNoExt)
+			patterns = append(patterns, exeNoExt)
+		}
+		for _, suffix := range []string{"-*.md", "_*.md", "-*.json", "-*.yaml", "-*.yml", "-*.jsonl", ".md", ".json", ".yaml", ".jsonl"} {
+			patterns = append(patterns, exeNoExt+suffix)
+		}
+		for _, p := range patterns {
+			if !slices.Contains(cfg.Exclude, p) {
+				cfg.Exclude = append(cfg.Exclude, p)
+				cfg.selfExclude = append(cfg.selfExclude, p)
+			}
 		}
-		cfg.Exclude = appendUnique(cfg.Exclude, fmt.Sprintf("%s-*.md", exeNoExt))
-		cfg.Exclude = appendUnique(cfg.Exclude, fmt.Sprintf("%s_*.md", exeNoExt))
-		cfg.Exclude = appendUnique(cfg.Exclude, fmt.Sprintf("%s-*.json", exeNoExt))
-		cfg.Exclude = appendUnique(cfg.Exclude, fmt.Sprintf("%s-*.yaml", exeNoExt))
-		cfg.Exclude = appendUnique(cfg.Exclude, fmt.Sprintf("%s-*.yml", exeNoExt))
-		cfg.Exclude = appendUnique(cfg.Exclude, fmt.Sprintf("%s.md", exeNoExt))
 	}
 
 	// Validate numeric options.
@@ -229,49 +516,109 @@ func runExtract(cmd *cobra.Command, args []string) error {
 		cfg.Depth = 1
 	}
 
-	// Perform extraction.
-	ctx, err := extractContext(cfg)
+	if cfg.StripPolicy == "" {
+		cfg.StripPolicy = "keep-directives"
+		if cfg.Docs {
+			cfg.StripPolicy = "non-doc"
+		}
+	}
+	switch cfg.StripPolicy {
+	case "all", "non-doc", "keep-directives", "license-only":
+	default:
+		return fmt.Errorf("unsupported strip policy: %s", cfg.StripPolicy)
+	}
+	if cfg.NotebookOutputs == "" {
+		cfg.NotebookOutputs = "drop"
+	}
+	switch cfg.NotebookOutputs {
+	case "drop", "truncate":
+	default:
+		return fmt.Errorf("unsupported notebook outputs: %s", cfg.NotebookOutputs)
+	}
+	for lang, mode := range cfg.Whitespace {
+		switch mode {
+		case "keep", "collapse", "remove":
+		default:
+			return fmt.Errorf("unsupported whitespace mode for %s: %s", lang, mode)
+		}
+	}
+	switch cfg.PlatformPolicy {
+	case "exclude", "down-weight":
+	default:
+		return fmt.Errorf("unsupported platform policy: %s", cfg.PlatformPolicy)
+	}
+	switch cfg.Generated {
+	case "keep", "exclude", "skeleton", "down-weight":
+	default:
+		return fmt.Errorf("unsupported generated policy: %s", cfg.Generated)
+	}
+
+	// The package graph is built from the AST-based package index.
+	if cfg.Graph {
+		cfg.AST = true
+		switch cfg.GraphFormat {
+		case "mermaid", "dot":
+		default:
+			return fmt.Errorf("unsupported graph format: %s", cfg.GraphFormat)
+		}
+	}
+
+	// Resolve the output extension early so unsupported formats fail fast.
+	ext, err := outputExtension(cfg.Format)
 	if err != nil {
-		return fmt.Errorf("failed to extract context: %w", err)
+		return err
 	}
 
-	outStr, err := generateOutput(ctx, cfg.Format)
+	// Perform extraction.
+	ctx, err := extractContext(cfg)
 	if err != nil {
-		return fmt.Errorf("failed to generate output: %w", err)
+		return fmt.Errorf("failed to extract context: %w", err)
 	}
 
 	// Determine output destination if not provided.
-	if cfg.Output == "" {
-		ext := "md"
-		switch strings.ToLower(cfg.Format) {
-		case "json":
-			ext = "json"
-		case "yaml", "yml":
-			ext = "yaml"
-		case "markdown", "md":
-			ext = "md"
-		}
-		tstamp := time.Now().UTC().Format("20060102_150405")
-		defaultName := fmt.Sprintf("%s-%s.%s", filepath.Base(strings.TrimSuffix(os.Args[0], filepath.Ext(os.Args[0]))), tstamp, ext)
-		outPath := filepath.Join(cfg.Path, defaultName)
-		if err := os.WriteFile(outPath, []byte(outStr), 0644); err != nil {
+	var out *os.File
+	outPath := cfg.Output
+	if outPath == "" {
+		base := filepath.Base(strings.TrimSuffix(os.Args[0], filepath.Ext(os.Args[0])))
+		defaultName := fmt.Sprintf("%s-%s.%s", base, time.Now().UTC().Format("20060102_150405"), ext)
+		if cfg.Reproducible {
+			// A stable name lets repeated runs overwrite the same bundle.
+			defaultName = fmt.Sprintf("%s.%s", base, ext)
+		}
+		outPath = filepath.Join(cfg.Path, defaultName)
+		if out, err = os.Create(outPath); err != nil {
 			// fallback to cwd
 			cwd, _ := os.Getwd()
 			outPath = filepath.Join(cwd, defaultName)
-			if err2 := os.WriteFile(outPath, []byte(outStr), 0644); err2 != nil {
+			if out, err = os.Create(outPath); err != nil {
 				// final fallback: stdout
 				fmt.Fprintln(os.Stderr, "Warning: failed to write to project dir or cwd; printing to stdout")
-				fmt.Print(outStr)
-				return nil
+				out, outPath = os.Stdout, ""
 			}
 		}
-		fmt.Printf("Context extracted successfully to %s\n", outPath)
 	} else {
 		// Write to user-specified output.
-		if err := os.WriteFile(cfg.Output, []byte(outStr), 0644); err != nil {
+		if out, err = os.Create(outPath); err != nil {
 			return fmt.Errorf("failed to write output file: %w", err)
 		}
-		fmt.Printf("Context extracted successfully to %s\n", cfg.Output)
+	}
+
+	// Stream the rendered context to the destination.
+	bw := bufio.NewWriter(out)
+	err = writeOutput(bw, ctx, cfg)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if out != os.Stdout {
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to generate output: %w", err)
+	}
+	if outPath != "" {
+		fmt.Printf("Context extracted successfully to %s\n", outPath)
 	}
 
 	// Inform user if estimated tokens exceed configured maximum.
@@ -282,16 +629,6 @@ func runExtract(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-// appendUnique appends val to slice only if it's not already present.
-func appendUnique(slice []string, val string) []string {
-	for _, s := range slice {
-		if s == val {
-			return slice
-		}
-	}
-	return append(slice, val)
-}
-
 // extractContext walks the project tree, filters files, and produces a Context.
 func extractContext(cfg *Config) (*Context, error) {
 	absPath, err := filepath.Abs(cfg.Path)
@@ -311,7 +648,7 @@ func extractContext(cfg *Config) (*Context, error) {
 	}
 
 	// Walk the filesystem to collect files and build a human-friendly tree string.
-	var treeBuf bytes.Buffer
+	var tree []treeEntry
 	files := []string{}
 
 	err = filepath.Walk(cfg.Path, func(path string, info os.FileInfo, wErr error) error {
@@ -332,14 +669,12 @@ func extractContext(cfg *Config) (*Context, error) {
 			}
 			return nil
 		}
+		if !info.IsDir() && filteredOut(path, relPath, cfg) {
+			return nil
+		}
 
-		depth := strings.Count(relPath, string(os.PathSeparator))
-		indent := strings.Repeat("  ", depth)
-		name := filepath.Base(relPath)
-		if info.IsDir() {
-			fmt.Fprintf(&treeBuf, "%s%s/\n", indent, name)
-		} else {
-			fmt.Fprintf(&treeBuf, "%s%s\n", indent, name)
+		tree = append(tree, treeEntry{relPath: relPath, dir: info.IsDir()})
+		if !info.IsDir() {
 			files = append(files, path)
 		}
 		return nil
@@ -348,7 +683,8 @@ func extractContext(cfg *Config) (*Context, error) {
 		return nil, err
 	}
 
-	ctx.TreeStructure = treeBuf.String()
+	ctx.TreeStructure = renderTree(tree)
+	cfg.cppProject = hasCppSources(files)
 
 	// Concurrent processing of files using worker goroutines.
 	fileCh := make(chan string, len(files))
@@ -361,11 +697,15 @@ func extractContext(cfg *Config) (*Context, error) {
 		go func() {
 			defer wg.Done()
 			for path := range fileCh {
-				fi, err := processFile(path, cfg)
+				fi, err := processFile(path, cfg, nil)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Warning: failed to process %s: %v\n", path, err)
 					continue
 				}
+				if cfg.Stream {
+					// Contents are re-read when the output is written.
+					fi.Content = ""
+				}
 				resultCh <- fi
 			}
 		}()
@@ -387,44 +727,146 @@ func extractContext(cfg *Config) (*Context, error) {
 		ctx.TotalSize += fi.Size
 	}
 
+	// Workers finish in arbitrary order; sort so output is deterministic.
+	sort.Slice(ctx.Files, func(i, j int) bool { return ctx.Files[i].Path < ctx.Files[j].Path })
+
 	ctx.TotalFiles = len(ctx.Files)
 
-	// If AST extraction or focus tracing is requested, perform lightweight Go analysis.
+	// Method sets span files, so they are attached once all files are
+	// parsed.
+	if cfg.AST {
+		attachMethodSets(ctx)
+	}
+
+	// If AST extraction or focus tracing is requested, build the symbol graph.
+	var sa *symbolAnalysis
 	if cfg.AST || cfg.Focus != "" {
-		performGoAnalysis(ctx, cfg)
+		sa = analyzeSymbols(ctx, cfg)
+		if cfg.WithTests && len(sa.Roots) > 0 {
+			for _, relPath := range pairTests(ctx, cfg, sa.Roots) {
+				tree = append(tree, treeEntry{relPath: relPath})
+			}
+			ctx.TreeStructure = renderTree(tree)
+		}
 	}
 
 	ctx.EstimatedTokens = estimateTokens(ctx)
 
+	// Record provenance using the effective configuration. Absolute paths
+	// differ between machines, so the project is recorded relative to the
+	// working directory and the output not at all. Reproducible bundles
+	// record nothing that depends on where or by what executable they are
+	// made.
+	recorded := *cfg
+	recorded.Path, recorded.Output = ".", ""
+	if cfg.Reproducible {
+		recorded.Exclude = slices.DeleteFunc(slices.Clone(cfg.Exclude), func(p string) bool {
+			return slices.Contains(cfg.selfExclude, p)
+		})
+	} else if cwd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(cwd, absPath); err == nil {
+			recorded.Path = filepath.ToSlash(rel)
+		}
+	}
+	ctx.Metadata = &Metadata{
+		Version:   version,
+		Config:    recorded,
+		Git:       readGitInfo(cfg.Path),
+		Tokenizer: tokenizerName,
+	}
+
 	// If the result exceeds token limit, trim files heuristically.
 	if cfg.MaxTokens > 0 && ctx.EstimatedTokens > cfg.MaxTokens {
 		trimmed, truncated := trimFilesToTokenLimit(ctx, cfg.MaxTokens)
+		// Trimming orders files by weight; output lists them by path.
+		sort.Slice(trimmed, func(i, j int) bool { return trimmed[i].Path < trimmed[j].Path })
 		ctx.Files = trimmed
 		ctx.TotalFiles = len(trimmed)
 		var totalSize int64
 		for _, f := range trimmed {
-			totalSize += f.Size
+			totalSize += f.bundledSize()
 		}
 		ctx.TotalSize = totalSize
 		ctx.EstimatedTokens = estimateTokens(ctx)
 		ctx.Truncated = truncated
 	}
 
+	// The package index and graphs cover the files that made it into the
+	// bundle.
+	if cfg.AST {
+		modulePath := readModulePath(cfg.Path)
+		ctx.Packages = buildPackageIndex(ctx, modulePath)
+		if cfg.Graph {
+			kept := map[string]bool{}
+			for _, f := range ctx.Files {
+				kept[f.Path] = true
+			}
+			ctx.Graph = buildGraphs(ctx, cfg, modulePath, sa.callEdges(kept))
+		}
+	}
+
+	if cfg.Reproducible {
+		ctx.ProjectPath = "."
+	}
 	return ctx, nil
 }
 
+// filteredOut reports whether the file at path is dropped for its contents:
+// a Go file not built for the target platform, or a generated file when
+// those are excluded. Only the header of a possibly generated file is read.
+func filteredOut(path, relPath string, cfg *Config) bool {
+	if cfg.PlatformPolicy == "exclude" && platformFilterEnabled(cfg) && strings.HasSuffix(path, ".go") {
+		if src, err := os.ReadFile(path); err == nil && !goFileMatchesPlatform(relPath, src, cfg) {
+			return true
+		}
+	}
+	return cfg.Generated == "exclude" && fileIsGenerated(path)
+}
+
+// treeEntry is a file or directory listed in the tree structure.
+type treeEntry struct {
+	relPath string
+	dir     bool
+}
+
+// renderTree lists entries as an indented tree, in the order of a walk:
+// each directory is followed by its contents, sorted by name.
+func renderTree(entries []treeEntry) string {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return slices.Compare(strings.Split(entries[i].relPath, string(os.PathSeparator)),
+			strings.Split(entries[j].relPath, string(os.PathSeparator))) < 0
+	})
+	var b strings.Builder
+	for _, e := range entries {
+		indent := strings.Repeat("  ", strings.Count(e.relPath, string(os.PathSeparator)))
+		name := filepath.Base(e.relPath)
+		if e.dir {
+			name += "/"
+		}
+		fmt.Fprintf(&b, "%s%s\n", indent, name)
+	}
+	return b.String()
+}
+
 // processFile reads file bytes, decides language, strips comments (optional), and returns FileInfo.
-func processFile(path string, cfg *Config) (*FileInfo, error) {
+// If ranges is non-empty only those lines of the file are kept.
+func processFile(path string, cfg *Config, ranges []LineRange) (*FileInfo, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
 
 	relPath, _ := filepath.Rel(cfg.Path, path)
-	ext := strings.ToLower(filepath.Ext(path))
-	language := languageMap[ext]
-	if language == "" {
-		language = "plaintext"
+	language := detectLanguage(relPath, data, cfg)
+	sum := sha256Hex(data)
+
+	// Notebooks are read as a script of their cells; ones that fail to
+	// decode are kept as they are.
+	var notebookLines map[int]bool
+	if strings.EqualFold(filepath.Ext(relPath), ".ipynb") {
+		if src, lang, protected, err := notebookSource(data, cfg.NotebookOutputs); err == nil {
+			data, language, notebookLines = src, lang, protected
+		}
 	}
 
 	info, err := os.Stat(path)
@@ -434,38 +876,99 @@ func processFile(path string, cfg *Config) (*FileInfo, error) {
 
 	// If file looks binary, include a small placeholder rather than raw contents.
 	if isBinary(data) {
-		return &FileInfo{
+		fi := &FileInfo{
 			Path:     relPath,
 			Language: "binary",
 			Content:  fmt.Sprintf("<binary file omitted, %d bytes>", info.Size()),
 			Size:     info.Size(),
+			SHA256:   sum,
 			Weight:   0, // binaries are deprioritized
-		}, nil
+		}
+		fi.ContentLen = len(fi.Content)
+		return fi, nil
 	}
 
+	generated := isGenerated(data)
+	wantSkeleton := useSkeleton(relPath, language, cfg) ||
+		(generated && cfg.Generated == "skeleton" && analyzerFor(language) != nil)
+
 	// Avoid embedding very large files to keep token usage reasonable.
 	const maxContentBytes = 1 << 20 // 1 MB
 	contentStr := string(data)
-	if info.Size() > int64(maxContentBytes) {
-		contentStr = fmt.Sprintf("<file too large, %d bytes, omitted>", info.Size())
-	} else {
+	skeleton := false
+	var skeletonLines []sourceLine
+	if wantSkeleton && len(data) <= maxContentBytes {
+		// Files that fail to parse, or whose language has no skeleton
+		// form, are kept in full.
+		skeletonLines, err = analyzerFor(language).Skeleton(data)
+		wantSkeleton = err == nil
+	}
+	whitespace := whitespaceMode(language, cfg)
+	stripDocs := cfg.StripDocstrings && language == "python"
+	if len(data) > maxContentBytes {
+		contentStr = fmt.Sprintf("<file too large, %d bytes, omitted>", len(data))
+	} else if cfg.StripComments || stripDocs || whitespace != "keep" || cfg.LineNumbers || len(ranges) > 0 || wantSkeleton {
+		// Work line by line so original line numbers survive stripping.
+		all := splitSourceLines(contentStr)
+		lines := selectLineRanges(all, ranges)
+		if wantSkeleton {
+			lines, skeleton = skeletonLines, true
+		}
+		if stripDocs {
+			doc := pythonDocstringLines(data)
+			lines = slices.DeleteFunc(lines, func(l sourceLine) bool { return doc[l.Num] })
+		}
+		// The whitespace of YAML block scalars is part of their value, and
+		// the markdown cells and outputs of notebooks are not comments.
+		verbatim := notebookLines
+		if language == "yaml" {
+			verbatim = yamlBlockScalarLines(data)
+		}
 		if cfg.StripComments {
-			contentStr = stripComments(contentStr, language)
+			keep := verbatim
+			if cfg.StripPolicy == "non-doc" && language == "go" {
+				if keep = goDocLines(data); keep == nil {
+					keep = map[int]bool{}
+				}
+				for n := range verbatim {
+					keep[n] = true
+				}
+			}
+			lines = stripComments(lines, language, cfg.StripPolicy, keep)
+		}
+		lines = compactWhitespace(lines, whitespace, verbatim)
+		if !cfg.LineNumbers {
+			lines = markRangeGaps(lines, all, ranges)
 		}
+		contentStr = joinSourceLines(lines, cfg.LineNumbers)
 	}
 
 	fi := &FileInfo{
-		Path:     relPath,
-		Language: language,
-		Content:  contentStr,
-		Size:     info.Size(),
-		Weight:   1,
+		Path:      relPath,
+		Language:  language,
+		Content:   contentStr,
+		Size:      info.Size(),
+		SHA256:    sum,
+		Skeleton:  skeleton,
+		Generated: generated,
+		Ranges:    ranges,
+		Weight:    1,
 	}
+	fi.ContentLen = len(contentStr)
 
-	// Optionally parse a lightweight AST summary for Go files.
-	if cfg.AST && language == "go" {
-		astInfo := parseGoASTFromBytes([]byte(contentStr))
-		fi.AST = astInfo
+	// Go files built only for other platforms are kept last when trimming.
+	if language == "go" && platformFilterEnabled(cfg) && !goFileMatchesPlatform(relPath, data, cfg) {
+		fi.Weight = 0
+	}
+	// Generated files are large and rarely what a reader is after, so they
+	// are trimmed first unless kept deliberately.
+	if generated && cfg.Generated != "keep" {
+		fi.Weight = 0
+	}
+
+	// Optionally parse a lightweight AST summary with the language's analyzer.
+	if a := analyzerFor(language); cfg.AST && a != nil {
+		fi.AST = a.Summarize(data, relPath, cfg)
 	}
 
 	return fi, nil
@@ -512,7 +1015,8 @@ func isBinary(data []byte) bool {
 
 // parseGoASTFromBytes returns a compact AST summary for a Go source file.
 // It intentionally keeps the result small and robust to parse errors.
-func parseGoASTFromBytes(src []byte) *ASTInfo {
+// Doc comments of the package, types and functions are captured if withDocs is set.
+func parseGoASTFromBytes(src []byte, withDocs bool) *ASTInfo {
 	fset := token.NewFileSet()
 	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
 	if err != nil {
@@ -523,205 +1027,401 @@ func parseGoASTFromBytes(src []byte) *ASTInfo {
 	if f.Name != nil {
 		ai.Package = f.Name.Name
 	}
+	doc := func(cg *ast.CommentGroup) string {
+		if !withDocs || cg == nil {
+			return ""
+		}
+		return strings.TrimSpace(cg.Text())
+	}
+	ai.Doc = doc(f.Doc)
 	for _, imp := range f.Imports {
 		str := strings.Trim(imp.Path.Value, `"`)
 		ai.Imports = append(ai.Imports, str)
 	}
-	// Collect top-level structs and functions.
+	// Collect top-level declarations.
 	for _, decl := range f.Decls {
 		switch d := decl.(type) {
 		case *ast.GenDecl:
-			// Type declarations -> record struct names.
 			for _, spec := range d.Specs {
-				if ts, ok := spec.(*ast.TypeSpec); ok {
-					switch ts.Type.(type) {
-					case *ast.StructType:
-						ai.Structs = append(ai.Structs, ts.Name.Name)
+				switch sp := spec.(type) {
+				case *ast.TypeSpec:
+					// An ungrouped declaration carries its doc on the GenDecl.
+					cg := sp.Doc
+					if cg == nil && !d.Lparen.IsValid() {
+						cg = d.Doc
+					}
+					addTypeSpec(ai, sp, doc(cg))
+				case *ast.ValueSpec:
+					for i, name := range sp.Names {
+						if name.Name == "_" {
+							continue
+						}
+						vi := ValueInfo{Name: name.Name, Exported: name.IsExported()}
+						if sp.Type != nil {
+							vi.Type = exprString(sp.Type)
+						}
+						if d.Tok == token.CONST && i < len(sp.Values) {
+							vi.Value = exprString(sp.Values[i])
+						}
+						if d.Tok == token.CONST {
+							ai.Consts = append(ai.Consts, vi)
+						} else {
+							ai.Vars = append(ai.Vars, vi)
+						}
 					}
 				}
 			}
 		case *ast.FuncDecl:
+			fi := FuncInfo{
+				Name:      d.Name.Name,
+				Signature: funcSignature(d),
+				Doc:       doc(d.Doc),
+				Exported:  d.Name.IsExported(),
+			}
 			if d.Recv != nil && len(d.Recv.List) > 0 {
-				// method: include receiver type as prefix
-				recvType := exprString(d.Recv.List[0].Type)
-				ai.Functions = append(ai.Functions, fmt.Sprintf("(%s).%s", recvType, d.Name.Name))
-			} else {
-				ai.Functions = append(ai.Functions, d.Name.Name)
+				// method: include receiver type
+				fi.Receiver = exprString(d.Recv.List[0].Type)
 			}
+			ai.Functions = append(ai.Functions, fi)
 		}
 	}
 	return ai
 }
 
-// exprString renders a small subset of ast.Expr to a string.
-// This helper is intentionally minimal — it avoids importing go/printer.
-func exprString(expr ast.Expr) string {
-	var buf bytes.Buffer
-	_ = formatNode(&buf, expr)
-	return buf.String()
+// addTypeSpec records a type declaration in the matching ASTInfo bucket.
+func addTypeSpec(ai *ASTInfo, ts *ast.TypeSpec, doc string) {
+	typeParams := ""
+	if ts.TypeParams != nil && len(ts.TypeParams.List) > 0 {
+		typeParams = "[" + fieldListString(ts.TypeParams) + "]"
+	}
+	exported := ts.Name.IsExported()
+	switch t := ts.Type.(type) {
+	case *ast.StructType:
+		if ts.Assign.IsValid() {
+			break
+		}
+		ai.Structs = append(ai.Structs, StructInfo{
+			Name:       ts.Name.Name,
+			TypeParams: typeParams,
+			Doc:        doc,
+			Fields:     structFields(t),
+			Exported:   exported,
+		})
+		return
+	case *ast.InterfaceType:
+		if ts.Assign.IsValid() {
+			break
+		}
+		ii := InterfaceInfo{Name: ts.Name.Name, TypeParams: typeParams, Doc: doc, Exported: exported}
+		for _, m := range t.Methods.List {
+			if ft, ok := m.Type.(*ast.FuncType); ok && len(m.Names) > 0 {
+				// Render "Name(params) results" by dropping the leading "func".
+				ii.Methods = append(ii.Methods, m.Names[0].Name+strings.TrimPrefix(exprString(ft), "func"))
+			} else {
+				ii.Embeds = append(ii.Embeds, exprString(m.Type))
+			}
+		}
+		ai.Interfaces = append(ai.Interfaces, ii)
+		return
+	}
+	ai.Types = append(ai.Types, TypeInfo{
+		Name:       ts.Name.Name,
+		TypeParams: typeParams,
+		Doc:        doc,
+		Type:       exprString(ts.Type),
+		Alias:      ts.Assign.IsValid(),
+		Exported:   exported,
+	})
 }
 
-// formatNode writes a small set of expression node types to w.
-// It handles basic identifiers, pointers, and selector expressions.
-func formatNode(w io.Writer, n interface{}) error {
-	switch v := n.(type) {
-	case *ast.Ident:
-		_, _ = io.WriteString(w, v.Name)
-	case *ast.StarExpr:
-		_, _ = io.WriteString(w, "*")
-		_ = formatNode(w, v.X)
-	case *ast.SelectorExpr:
-		_ = formatNode(w, v.X)
-		_, _ = io.WriteString(w, ".")
-		_ = formatNode(w, v.Sel)
-	default:
-		// unsupported node types are omitted for brevity.
+// structFields lists the fields of a struct type in declaration order.
+func structFields(st *ast.StructType) []FieldInfo {
+	var fields []FieldInfo
+	for _, field := range st.Fields.List {
+		typ := exprString(field.Type)
+		tag := ""
+		if field.Tag != nil {
+			if t, err := strconv.Unquote(field.Tag.Value); err == nil {
+				tag = t
+			}
+		}
+		if len(field.Names) == 0 {
+			// Embedded field: its name is the type name without pointer or package.
+			name := strings.TrimPrefix(recvTypeName(field.Type), "*")
+			if i := strings.LastIndex(name, "."); i >= 0 {
+				name = name[i+1:]
+			}
+			fields = append(fields, FieldInfo{Name: name, Type: typ, Tag: tag, Embedded: true, Exported: ast.IsExported(name)})
+			continue
+		}
+		for _, n := range field.Names {
+			fields = append(fields, FieldInfo{Name: n.Name, Type: typ, Tag: tag, Exported: n.IsExported()})
+		}
 	}
-	return nil
+	return fields
 }
 
-// performGoAnalysis builds a simple call graph for Go files and marks files
-// according to the configured focus symbol and depth. Marking influences
-// which files are kept when trimming to token limits.
-func performGoAnalysis(ctx *Context, cfg *Config) {
-	// funcLoc holds function location metadata used to map functions to files.
-	type funcLoc struct {
-		File   string
-		Name   string
-		Start  int
-		End    int
-		Weight int
-	}
-	funcs := map[string]*funcLoc{}
-	fileSrc := map[string][]byte{}
-
-	// Parse all Go files and collect function positions.
-	fset := token.NewFileSet()
-	fileASTs := map[string]*ast.File{}
+// attachMethodSets adds to each struct summary the methods declared on it
+// in any file of the same package (same directory and package name).
+func attachMethodSets(ctx *Context) {
+	pkgKey := func(f *FileInfo) string {
+		return filepath.Dir(f.Path) + "|" + f.AST.Package
+	}
+	// package -> receiver type name -> method signatures
+	methods := map[string]map[string][]string{}
 	for i := range ctx.Files {
 		f := &ctx.Files[i]
-		if f.Language != "go" {
+		if f.AST == nil || f.Language != "go" {
 			continue
 		}
-		raw, err := os.ReadFile(filepath.Join(ctx.ProjectPath, f.Path))
-		if err != nil {
+		key := pkgKey(f)
+		for _, fn := range f.AST.Functions {
+			if fn.Receiver == "" {
+				continue
+			}
+			recv := recvBaseName(fn.Receiver)
+			if methods[key] == nil {
+				methods[key] = map[string][]string{}
+			}
+			methods[key][recv] = append(methods[key][recv], strings.TrimPrefix(fn.Signature, "func "))
+		}
+	}
+	for i := range ctx.Files {
+		f := &ctx.Files[i]
+		if f.AST == nil {
 			continue
 		}
-		fileSrc[f.Path] = raw
-		astFile, err := parser.ParseFile(fset, f.Path, raw, parser.ParseComments)
-		if err != nil {
+		for j := range f.AST.Structs {
+			st := &f.AST.Structs[j]
+			st.Methods = methods[pkgKey(f)][st.Name]
+		}
+	}
+}
+
+// buildPackageIndex groups Go files into packages keyed by import path and
+// records each package's exported API, declared outside test files, and
+// its imports of other project packages. Without a module path, import paths are relative directories.
+func buildPackageIndex(ctx *Context, modulePath string) []PackageInfo {
+	byPath := map[string]*PackageInfo{}
+	imports := map[string]map[string]struct{}{}
+	for _, f := range ctx.Files {
+		if f.AST == nil || f.Language != "go" {
 			continue
 		}
-		fileASTs[f.Path] = astFile
-
-		for _, decl := range astFile.Decls {
-			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Name != nil {
-				name := fd.Name.Name
-				start := fset.Position(fd.Pos()).Offset
-				end := fset.Position(fd.End()).Offset
-				key := name
-				// include receiver type for methods to disambiguate
-				if fd.Recv != nil && len(fd.Recv.List) > 0 {
-					recv := exprString(fd.Recv.List[0].Type)
-					key = fmt.Sprintf("%s.%s", recv, name)
-				}
-				funcs[key] = &funcLoc{
-					File:   f.Path,
-					Name:   key,
-					Start:  start,
-					End:    end,
-					Weight: 10,
-				}
+		importPath := packageImportPath(modulePath, &f)
+		pkg, ok := byPath[importPath]
+		if !ok {
+			pkg = &PackageInfo{ImportPath: importPath, Name: f.AST.Package}
+			byPath[importPath] = pkg
+			imports[importPath] = map[string]struct{}{}
+		}
+		pkg.Files = append(pkg.Files, f.Path)
+		// Tests, benchmarks and helpers of test files are not part of the
+		// package's API.
+		if !strings.HasSuffix(f.Path, "_test.go") {
+			pkg.Exported = append(pkg.Exported, exportedNames(f.AST)...)
+		}
+		for _, imp := range f.AST.Imports {
+			imports[importPath][imp] = struct{}{}
+		}
+	}
+
+	pkgs := make([]PackageInfo, 0, len(byPath))
+	for importPath, pkg := range byPath {
+		for imp := range imports[importPath] {
+			if _, internal := byPath[imp]; internal && imp != importPath {
+				pkg.Imports = append(pkg.Imports, imp)
 			}
 		}
+		sort.Strings(pkg.Files)
+		sort.Strings(pkg.Exported)
+		sort.Strings(pkg.Imports)
+		pkgs = append(pkgs, *pkg)
 	}
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].ImportPath < pkgs[j].ImportPath })
+	return pkgs
+}
 
-	// Build a simple call graph: caller -> callee set
-	callGraph := map[string]map[string]struct{}{}
-	for _, astFile := range fileASTs {
-		ast.Inspect(astFile, func(n ast.Node) bool {
-			// Look for CallExpr and extract a callee name in common forms.
-			if call, ok := n.(*ast.CallExpr); ok {
-				var callee string
-				switch fun := call.Fun.(type) {
-				case *ast.Ident:
-					callee = fun.Name
-				case *ast.SelectorExpr:
-					// could be pkg.Func or expr.Method
-					if id, ok := fun.X.(*ast.Ident); ok {
-						callee = fmt.Sprintf("%s.%s", id.Name, fun.Sel.Name)
-					} else {
-						// fallback to method name only
-						callee = fun.Sel.Name
-					}
-				}
-				// Find enclosing function for this call and connect edges.
-				parent := findEnclosingFunc(astFile, call.Pos())
-				if parent != nil && parent.Name != nil {
-					parentName := parent.Name.Name
-					if parent.Recv != nil && len(parent.Recv.List) > 0 {
-						r := exprString(parent.Recv.List[0].Type)
-						parentName = fmt.Sprintf("%s.%s", r, parentName)
-					}
-					if _, ok := callGraph[parentName]; !ok {
-						callGraph[parentName] = map[string]struct{}{}
-					}
-					if callee != "" {
-						callGraph[parentName][callee] = struct{}{}
-					}
-				}
+// packageImportPath returns the import path of the package containing the
+// Go file f, which must have an AST summary.
+func packageImportPath(modulePath string, f *FileInfo) string {
+	importPath := path.Join(modulePath, filepath.ToSlash(filepath.Dir(f.Path)))
+	if strings.HasSuffix(f.AST.Package, "_test") {
+		importPath += "_test"
+	}
+	return importPath
+}
+
+// exportedNames lists the exported top-level identifiers of a file.
+// Methods are reported as Type.Method.
+func exportedNames(ai *ASTInfo) []string {
+	var names []string
+	for _, st := range ai.Structs {
+		if st.Exported {
+			names = append(names, st.Name)
+		}
+	}
+	for _, it := range ai.Interfaces {
+		if it.Exported {
+			names = append(names, it.Name)
+		}
+	}
+	for _, t := range ai.Types {
+		if t.Exported {
+			names = append(names, t.Name)
+		}
+	}
+	for _, v := range append(append([]ValueInfo{}, ai.Consts...), ai.Vars...) {
+		if v.Exported {
+			names = append(names, v.Name)
+		}
+	}
+	for _, fn := range ai.Functions {
+		if !fn.Exported {
+			continue
+		}
+		if fn.Receiver != "" {
+			recv := recvBaseName(fn.Receiver)
+			if !ast.IsExported(recv) {
+				continue
 			}
-			return true
-		})
+			names = append(names, recv+"."+fn.Name)
+			continue
+		}
+		names = append(names, fn.Name)
 	}
+	return names
+}
 
-	// If a focus symbol is provided, perform a breadth-first search from it
-	// and boost weights for visited functions/files to prioritize them.
-	if cfg.Focus != "" {
-		queue := []string{cfg.Focus}
-		visited := map[string]struct{}{}
-		depth := 0
-		nextQueue := []string{}
-		for depth <= cfg.Depth && len(queue) > 0 {
-			for _, cur := range queue {
-				// match function keys by exact or suffix match
-				for k, fl := range funcs {
-					if k == cur || strings.HasSuffix(k, cur) || strings.HasSuffix(k, "."+cur) {
-						visited[k] = struct{}{}
-						// mark file's weight high
-						for i := range ctx.Files {
-							if ctx.Files[i].Path == fl.File {
-								ctx.Files[i].Weight += 1000
-							}
-						}
-						// enqueue callees for next level
-						if callees, ok := callGraph[k]; ok {
-							for callee := range callees {
-								nextQueue = append(nextQueue, callee)
-							}
-						}
-					}
+// recvBaseName strips the pointer and type parameters from a rendered
+// receiver type, e.g. "*List[T]" becomes "List".
+func recvBaseName(receiver string) string {
+	recv := strings.TrimPrefix(receiver, "*")
+	if i := strings.Index(recv, "["); i >= 0 {
+		recv = recv[:i]
+	}
+	return recv
+}
+
+// readModulePath returns the import path of projectPath, from the module
+// path declared in the go.mod of projectPath or its nearest ancestor, or ""
+// if there is none.
+func readModulePath(projectPath string) string {
+	dir, err := filepath.Abs(projectPath)
+	if err != nil {
+		return ""
+	}
+	sub := ""
+	for {
+		if data, err := os.ReadFile(filepath.Join(dir, "go.mod")); err == nil {
+			for _, ln := range strings.Split(string(data), "\n") {
+				fields := strings.Fields(ln)
+				if len(fields) >= 2 && fields[0] == "module" {
+					return path.Join(strings.Trim(fields[1], `"`), sub)
 				}
 			}
-			queue = nextQueue
-			nextQueue = []string{}
-			depth++
-		}
-		// Also mark callers of the visited functions to preserve context.
-		for caller, callees := range callGraph {
-			for callee := range callees {
-				if _, ok := visited[callee]; ok {
-					if callerFL, ok := funcs[caller]; ok {
-						for i := range ctx.Files {
-							if ctx.Files[i].Path == callerFL.File {
-								ctx.Files[i].Weight += 500
-							}
-						}
-					}
-				}
+			return ""
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		sub = path.Join(filepath.Base(dir), sub)
+		dir = parent
+	}
+}
+
+// goDocLines returns the line numbers covered by doc comments of the
+// package clause, type declarations and functions in a Go source file.
+func goDocLines(src []byte) map[int]bool {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	lines := map[int]bool{}
+	mark := func(cg *ast.CommentGroup) {
+		if cg == nil {
+			return
+		}
+		for ln := fset.Position(cg.Pos()).Line; ln <= fset.Position(cg.End()).Line; ln++ {
+			lines[ln] = true
+		}
+	}
+	mark(f.Doc)
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			mark(d.Doc)
+		case *ast.GenDecl:
+			if d.Tok != token.TYPE {
+				continue
+			}
+			mark(d.Doc)
+			for _, spec := range d.Specs {
+				mark(spec.(*ast.TypeSpec).Doc)
 			}
 		}
 	}
+	return lines
+}
+
+// funcSignature renders a function declaration without its doc comment or body.
+func funcSignature(fd *ast.FuncDecl) string {
+	sig := *fd
+	sig.Doc = nil
+	sig.Body = nil
+	return exprString(&sig)
+}
+
+// fieldListString renders the fields of a parameter or type parameter list
+// without the surrounding brackets.
+func fieldListString(fl *ast.FieldList) string {
+	parts := make([]string, 0, len(fl.List))
+	for _, field := range fl.List {
+		names := make([]string, len(field.Names))
+		for i, n := range field.Names {
+			names[i] = n.Name
+		}
+		typ := exprString(field.Type)
+		if len(names) > 0 {
+			typ = strings.Join(names, ", ") + " " + typ
+		}
+		parts = append(parts, typ)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// exprString renders an AST node on a single line using go/printer.
+// Positions are ignored so multi-line source collapses to one line.
+func exprString(node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, token.NewFileSet(), node); err != nil {
+		return ""
+	}
+	return buf.String()
+}
+
+// recvTypeName returns a method receiver's type without type parameters,
+// e.g. "*List" for "*List[T]", so that call graph keys stay matchable.
+func recvTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return "*" + recvTypeName(t.X)
+	case *ast.IndexExpr:
+		return recvTypeName(t.X)
+	case *ast.IndexListExpr:
+		return recvTypeName(t.X)
+	default:
+		return exprString(expr)
+	}
+}
+
+// matchesSymbol reports whether the function key matches a focus or callee
+// name, exactly or as a qualified name (so "Save" and "Store.Save" match
+// "*Store.Save", but "Use" does not match "TestUse").
+func matchesSymbol(key, name string) bool {
+	return key == name || strings.TrimPrefix(key, "*") == name || strings.HasSuffix(key, "."+name)
 }
 
 // findEnclosingFunc returns the FuncDecl that contains pos, if any.
@@ -739,43 +1439,111 @@ func findEnclosingFunc(file *ast.File, pos token.Pos) *ast.FuncDecl {
 	return found
 }
 
-// stripComments removes comments for common languages using regex heuristics.
-// It is conservative: it removes single-line and multi-line comment forms,
-// then collapses empty lines to produce a denser output.
-func stripComments(content string, language string) string {
-	// Use robust regexes per language family.
-	switch language {
-	case "go", "java", "javascript", "typescript", "c", "cpp", "csharp", "rust", "swift", "kotlin", "scala":
-		reSingle := regexp.MustCompile(`(?m)//.*$`)
-		content = reSingle.ReplaceAllString(content, "")
-		reMulti := regexp.MustCompile(`(?s)/\*.*?\*/`)
-		content = reMulti.ReplaceAllString(content, "")
-	case "python", "ruby", "shell", "bash", "zsh", "powershell", "yaml", "r":
-		reHash := regexp.MustCompile(`(?m)#.*$`)
-		content = reHash.ReplaceAllString(content, "")
-	case "html", "xml":
-		re := regexp.MustCompile(`(?s)<!--.*?-->`)
-		content = re.ReplaceAllString(content, "")
-	case "css", "scss", "sass", "less":
-		re := regexp.MustCompile(`(?s)/\*.*?\*/`)
-		content = re.ReplaceAllString(content, "")
-	case "sql":
-		reLine := regexp.MustCompile(`(?m)--.*$`)
-		content = reLine.ReplaceAllString(content, "")
-		reMulti := regexp.MustCompile(`(?s)/\*.*?\*/`)
-		content = reMulti.ReplaceAllString(content, "")
-	}
-
-	// Trim trailing whitespace and remove empty lines to keep output compact.
-	lines := strings.Split(content, "\n")
-	out := make([]string, 0, len(lines))
+// sourceLine is one line of emitted content along with its 1-based line
+// number in the original file.
+type sourceLine struct {
+	Num  int
+	Text string
+}
+
+// splitSourceLines splits content into numbered lines. A trailing newline
+// does not produce an extra empty line.
+func splitSourceLines(content string) []sourceLine {
+	content = strings.TrimSuffix(content, "\n")
+	if content == "" {
+		return nil
+	}
+	parts := strings.Split(content, "\n")
+	lines := make([]sourceLine, len(parts))
+	for i, p := range parts {
+		lines[i] = sourceLine{Num: i + 1, Text: p}
+	}
+	return lines
+}
+
+// selectLineRanges keeps the lines that fall into any of ranges. With no
+// ranges all lines are kept.
+func selectLineRanges(lines []sourceLine, ranges []LineRange) []sourceLine {
+	if len(ranges) == 0 {
+		return lines
+	}
+	out := make([]sourceLine, 0, len(lines))
+	for _, ln := range lines {
+		for _, r := range ranges {
+			if ln.Num >= r.Start && ln.Num <= r.End {
+				out = append(out, ln)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// rangeGapMarker stands for the lines left out between two line ranges of
+// a file in content without line numbers.
+const rangeGapMarker = "// ..."
+
+// markRangeGaps inserts rangeGapMarker between consecutive lines that come
+// from ranges with non-blank lines of all, the whole file, left out between
+// them.
+func markRangeGaps(lines, all []sourceLine, ranges []LineRange) []sourceLine {
+	if len(ranges) == 0 {
+		return lines
+	}
+	omitted := func(n int) bool {
+		if n > len(all) || strings.TrimSpace(all[n-1].Text) == "" {
+			return false
+		}
+		for _, r := range ranges {
+			if n >= r.Start && n <= r.End {
+				return false
+			}
+		}
+		return true
+	}
+	out := make([]sourceLine, 0, len(lines)+len(ranges))
+	for i, ln := range lines {
+		if i > 0 {
+			for n := lines[i-1].Num + 1; n < ln.Num; n++ {
+				if omitted(n) {
+					out = append(out, sourceLine{Num: n, Text: rangeGapMarker})
+					break
+				}
+			}
+		}
+		out = append(out, ln)
+	}
+	return out
+}
+
+// joinSourceLines renders lines back into content, optionally prefixing each
+// line with its original line number right-aligned to a common width.
+func joinSourceLines(lines []sourceLine, numbered bool) string {
+	if !numbered {
+		texts := make([]string, len(lines))
+		for i, ln := range lines {
+			texts[i] = ln.Text
+		}
+		return strings.Join(texts, "\n")
+	}
+	width := 1
 	for _, ln := range lines {
-		ln = strings.TrimRight(ln, " \t")
-		if strings.TrimSpace(ln) != "" {
-			out = append(out, ln)
+		if w := len(fmt.Sprint(ln.Num)); w > width {
+			width = w
+		}
+	}
+	var b strings.Builder
+	for i, ln := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "%*d |", width, ln.Num)
+		if ln.Text != "" {
+			b.WriteString(" ")
+			b.WriteString(ln.Text)
 		}
 	}
-	return strings.Join(out, "\n")
+	return b.String()
 }
 
 // shouldExclude returns true if path should be skipped based on exclude/include patterns.
@@ -871,121 +1639,78 @@ func readGitignore(projectPath string) []string {
 	return res
 }
 
-// estimateTokens returns a rough token estimate based on total character length.
-// Heuristic: 1 token ≈ 4 characters.
-func estimateTokens(ctx *Context) int {
-	totalChars := len(ctx.TreeStructure)
-	for _, f := range ctx.Files {
-		totalChars += len(f.Path) + len(f.Content)
-		if f.AST != nil {
-			totalChars += len(strings.Join(f.AST.Functions, ",")) + len(strings.Join(f.AST.Structs, ","))
+// astSummaryLen approximates the number of characters an AST summary adds
+// to the output.
+func astSummaryLen(ai *ASTInfo) int {
+	n := len(ai.Package) + len(strings.Join(ai.Imports, ","))
+	for _, st := range ai.Structs {
+		n += len(st.Name) + len(st.TypeParams) + len(strings.Join(st.Methods, ","))
+		for _, fd := range st.Fields {
+			n += len(fd.Name) + len(fd.Type) + len(fd.Tag)
 		}
 	}
-	return totalChars / 4
-}
-
-// generateOutput serializes ctx into the requested format.
-func generateOutput(ctx *Context, format string) (string, error) {
-	switch strings.ToLower(format) {
-	case "json":
-		return generateJSON(ctx)
-	case "yaml", "yml":
-		return generateYAML(ctx)
-	case "markdown", "md":
-		return generateMarkdown(ctx)
-	default:
-		return "", fmt.Errorf("unsupported format: %s", format)
+	for _, it := range ai.Interfaces {
+		n += len(it.Name) + len(strings.Join(it.Methods, ",")) + len(strings.Join(it.Embeds, ","))
 	}
+	for _, t := range ai.Types {
+		n += len(t.Name) + len(t.Type)
+	}
+	for _, v := range append(append([]ValueInfo{}, ai.Consts...), ai.Vars...) {
+		n += len(v.Name) + len(v.Type) + len(v.Value)
+	}
+	n += len(strings.Join(ai.Exports, ",")) + len(strings.Join(ai.Components, ","))
+	for _, c := range ai.Classes {
+		n += len(c.Name) + len(strings.Join(c.Bases, ",")) + len(strings.Join(c.Fields, ",")) + len(strings.Join(c.Methods, ","))
+	}
+	for _, im := range ai.Impls {
+		n += len(im.TypeParams) + len(im.Trait) + len(im.Type) + len(strings.Join(im.Methods, ","))
+	}
+	n += len(strings.Join(ai.Modules, ","))
+	for _, fn := range ai.Functions {
+		n += len(fn.Signature) + len(strings.Join(fn.Decorators, ","))
+	}
+	return n
 }
 
-func generateJSON(ctx *Context) (string, error) {
-	b, err := json.MarshalIndent(ctx, "", "  ")
+// readGitInfo returns the HEAD commit and dirty state of the repository
+// containing projectPath, or nil if it is not a git work tree.
+// Only tracked files are considered when checking for local changes.
+func readGitInfo(projectPath string) *GitInfo {
+	out, err := exec.Command("git", "-C", projectPath, "rev-parse", "HEAD").Output()
 	if err != nil {
-		return "", err
+		return nil
+	}
+	gi := &GitInfo{Commit: strings.TrimSpace(string(out))}
+	status, err := exec.Command("git", "-C", projectPath, "status", "--porcelain", "--untracked-files=no").Output()
+	if err == nil {
+		gi.Dirty = len(bytes.TrimSpace(status)) > 0
 	}
-	return string(b), nil
+	return gi
 }
 
-func generateYAML(ctx *Context) (string, error) {
-	b, err := yaml.Marshal(ctx)
-	if err != nil {
-		return "", err
-	}
-	return string(b), nil
+// sha256Hex returns the hex-encoded SHA-256 digest of data.
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
 }
 
-// generateMarkdown creates a human-friendly markdown summary containing
-// the directory tree, per-file sections (AST summary if available), and content blocks.
-func generateMarkdown(ctx *Context) (string, error) {
-	var b strings.Builder
-	b.WriteString("# Project Context (Contextify)\n\n")
-	b.WriteString(fmt.Sprintf("**Project Path:** `%s`\n\n", ctx.ProjectPath))
-	b.WriteString(fmt.Sprintf("**Total Files:** %d\n\n", ctx.TotalFiles))
-	b.WriteString(fmt.Sprintf("**Total Size:** %d bytes\n\n", ctx.TotalSize))
-	b.WriteString(fmt.Sprintf("**Estimated Tokens:** %d\n\n", ctx.EstimatedTokens))
-	if ctx.Truncated {
-		b.WriteString("> **Note:** context was truncated to satisfy token limits.\n\n")
-	}
-	b.WriteString("## Directory Structure\n\n")
-	b.WriteString("```\n")
-	b.WriteString(ctx.TreeStructure)
-	b.WriteString("```\n\n")
-
-	// Group files by language for easier navigation.
-	filesByLang := map[string][]FileInfo{}
+// estimateTokens returns a rough token estimate based on total character length.
+// Heuristic: 1 token ≈ 4 characters.
+func estimateTokens(ctx *Context) int {
+	totalChars := len(ctx.TreeStructure)
 	for _, f := range ctx.Files {
-		filesByLang[f.Language] = append(filesByLang[f.Language], f)
-	}
-	langs := make([]string, 0, len(filesByLang))
-	for k := range filesByLang {
-		langs = append(langs, k)
-	}
-	sort.Strings(langs)
-
-	for _, lang := range langs {
-		files := filesByLang[lang]
-		b.WriteString(fmt.Sprintf("### %s Files\n\n", strings.Title(lang)))
-		// sort by path for stable output
-		sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
-		for _, f := range files {
-			b.WriteString(fmt.Sprintf("#### `%s` — %d bytes\n\n", f.Path, f.Size))
-			if f.AST != nil {
-				b.WriteString("**AST Summary:**\n\n")
-				if f.AST.Package != "" {
-					b.WriteString(fmt.Sprintf("- Package: `%s`\n", f.AST.Package))
-				}
-				if len(f.AST.Imports) > 0 {
-					b.WriteString(fmt.Sprintf("- Imports: `%s`\n", strings.Join(f.AST.Imports, ", ")))
-				}
-				if len(f.AST.Structs) > 0 {
-					b.WriteString(fmt.Sprintf("- Structs: `%s`\n", strings.Join(f.AST.Structs, ", ")))
-				}
-				if len(f.AST.Functions) > 0 {
-					b.WriteString(fmt.Sprintf("- Functions: `%s`\n", strings.Join(f.AST.Functions, ", ")))
-				}
-				b.WriteString("\n")
-			}
-
-			blockLang := lang
-			if blockLang == "plaintext" {
-				blockLang = ""
-			}
-			b.WriteString(fmt.Sprintf("```%s\n", blockLang))
-			b.WriteString(f.Content)
-			if !strings.HasSuffix(f.Content, "\n") {
-				b.WriteString("\n")
-			}
-			b.WriteString("```\n\n")
+		totalChars += len(f.Path) + f.ContentLen
+		if f.AST != nil {
+			totalChars += astSummaryLen(f.AST)
 		}
 	}
-
-	// Footer with generation timestamp.
-	b.WriteString(fmt.Sprintf("_Generated by Contextify on %s_\n", time.Now().UTC().Format(time.RFC3339)))
-	return b.String(), nil
+	return totalChars / 4
 }
 
-// loadConfigFile merges a YAML config file into cfg without overwriting CLI values.
-func loadConfigFile(path string, cfg *Config) error {
+// loadConfigFile merges a YAML config file into cfg without overwriting CLI
+// values. changed reports whether a flag was set on the command line, for
+// the options whose flags have a default.
+func loadConfigFile(path string, cfg *Config, changed func(flag string) bool) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return err
@@ -1007,6 +1732,15 @@ func loadConfigFile(path string, cfg *Config) error {
 	if !cfg.StripComments && fileCfg.StripComments {
 		cfg.StripComments = fileCfg.StripComments
 	}
+	if cfg.StripPolicy == "" && fileCfg.StripPolicy != "" {
+		cfg.StripPolicy = fileCfg.StripPolicy
+	}
+	if !cfg.StripDocstrings && fileCfg.StripDocstrings {
+		cfg.StripDocstrings = fileCfg.StripDocstrings
+	}
+	if cfg.NotebookOutputs == "" && fileCfg.NotebookOutputs != "" {
+		cfg.NotebookOutputs = fileCfg.NotebookOutputs
+	}
 	if cfg.MaxTokens == 0 && fileCfg.MaxTokens > 0 {
 		cfg.MaxTokens = fileCfg.MaxTokens
 	}
@@ -1022,6 +1756,71 @@ func loadConfigFile(path string, cfg *Config) error {
 	if cfg.Workers == 0 && fileCfg.Workers > 0 {
 		cfg.Workers = fileCfg.Workers
 	}
+	if !cfg.Stream && fileCfg.Stream {
+		cfg.Stream = fileCfg.Stream
+	}
+	if !cfg.LineNumbers && fileCfg.LineNumbers {
+		cfg.LineNumbers = fileCfg.LineNumbers
+	}
+	if !cfg.Reproducible && fileCfg.Reproducible {
+		cfg.Reproducible = fileCfg.Reproducible
+	}
+	if !cfg.Skeleton && fileCfg.Skeleton {
+		cfg.Skeleton = fileCfg.Skeleton
+	}
+	if len(fileCfg.SkeletonGlobs) > 0 && len(cfg.SkeletonGlobs) == 0 {
+		cfg.SkeletonGlobs = fileCfg.SkeletonGlobs
+	}
+	if !cfg.Docs && fileCfg.Docs {
+		cfg.Docs = fileCfg.Docs
+	}
+	if !cfg.Graph && fileCfg.Graph {
+		cfg.Graph = fileCfg.Graph
+	}
+	if !changed("graph-format") && fileCfg.GraphFormat != "" {
+		cfg.GraphFormat = fileCfg.GraphFormat
+	}
+	if !cfg.GraphExternal && fileCfg.GraphExternal {
+		cfg.GraphExternal = fileCfg.GraphExternal
+	}
+	if !cfg.WithTests && fileCfg.WithTests {
+		cfg.WithTests = fileCfg.WithTests
+	}
+	if !changed("test-weight") && fileCfg.TestWeight > 0 {
+		cfg.TestWeight = fileCfg.TestWeight
+	}
+	if cfg.GOOS == "" && fileCfg.GOOS != "" {
+		cfg.GOOS = fileCfg.GOOS
+	}
+	if cfg.GOARCH == "" && fileCfg.GOARCH != "" {
+		cfg.GOARCH = fileCfg.GOARCH
+	}
+	if len(fileCfg.Tags) > 0 && len(cfg.Tags) == 0 {
+		cfg.Tags = fileCfg.Tags
+	}
+	if !changed("platform-policy") && fileCfg.PlatformPolicy != "" {
+		cfg.PlatformPolicy = fileCfg.PlatformPolicy
+	}
+	if !changed("generated") && fileCfg.Generated != "" {
+		cfg.Generated = fileCfg.Generated
+	}
+	// Language overrides merge pattern by pattern.
+	for pat, lang := range fileCfg.Languages {
+		if _, ok := cfg.Languages[pat]; !ok {
+			if cfg.Languages == nil {
+				cfg.Languages = map[string]string{}
+			}
+			cfg.Languages[pat] = lang
+		}
+	}
+	for lang, mode := range fileCfg.Whitespace {
+		if _, ok := cfg.Whitespace[lang]; !ok {
+			if cfg.Whitespace == nil {
+				cfg.Whitespace = map[string]string{}
+			}
+			cfg.Whitespace[lang] = mode
+		}
+	}
 	return nil
 }
 
@@ -1038,18 +1837,21 @@ func trimFilesToTokenLimit(ctx *Context, tokenLimit int) ([]FileInfo, bool) {
 	files := make([]FileInfo, len(ctx.Files))
 	copy(files, ctx.Files)
 	sort.Slice(files, func(i, j int) bool {
-		// higher weight first; then smaller size first
+		// higher weight first; then smaller size first; then path for stability
 		if files[i].Weight != files[j].Weight {
 			return files[i].Weight > files[j].Weight
 		}
-		return files[i].Size < files[j].Size
+		if files[i].Size != files[j].Size {
+			return files[i].Size < files[j].Size
+		}
+		return files[i].Path < files[j].Path
 	})
 
 	acc := 0
 	out := []FileInfo{}
 	for _, f := range files {
 		// rough tokens for this file
-		toks := (len(f.Path) + len(f.Content)) / 4
+		toks := (len(f.Path) + f.ContentLen) / 4
 		if acc+toks > tokenLimit {
 			// skip file if it would exceed the limit
 			continue
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// notebookOutputLines is the number of lines kept of each cell output when
+// outputs are truncated.
+const notebookOutputLines = 10
+
+// notebook is the part of a Jupyter notebook needed to read it as a script.
+type notebook struct {
+	Metadata struct {
+		Kernelspec struct {
+			Language string `json:"language"`
+		} `json:"kernelspec"`
+		LanguageInfo struct {
+			Name string `json:"name"`
+		} `json:"language_info"`
+	} `json:"metadata"`
+	Cells []struct {
+		CellType string           `json:"cell_type"`
+		Source   notebookText     `json:"source"`
+		Outputs  []notebookOutput `json:"outputs"`
+	} `json:"cells"`
+}
+
+// notebookOutput is an output of a code cell: a stream, a result, display
+// data or an error.
+type notebookOutput struct {
+	OutputType string                     `json:"output_type"`
+	Text       notebookText               `json:"text"`
+	Data       map[string]json.RawMessage `json:"data"`
+	Ename      string                     `json:"ename"`
+	Evalue     string                     `json:"evalue"`
+}
+
+// notebookText is multiline text, stored either whole or as a list of
+// lines.
+type notebookText string
+
+func (t *notebookText) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*t = notebookText(s)
+		return nil
+	}
+	var lines []string
+	if err := json.Unmarshal(b, &lines); err != nil {
+		return err
+	}
+	*t = notebookText(strings.Join(lines, ""))
+	return nil
+}
+
+// notebookLanguages maps kernel languages to languages, where they differ.
+var notebookLanguages = map[string]string{
+	"c++":   "cpp",
+	"c++17": "cpp",
+	"c#":    "csharp",
+}
+
+// notebookSource converts a Jupyter notebook into a script of its kernel's
+// language in the "percent" format: each cell starts with a "# %%" line and
+// markdown and raw cells are commented out. Outputs are dropped or, if
+// outputs is "truncate", kept as comments after their cell, each cut to
+// notebookOutputLines lines and with images and HTML replaced by a note.
+// It returns the script, its language and the numbers of the lines that
+// are notebook content rather than comments of the code: the cell
+// markers, the markdown and raw cells and the outputs, which stripping
+// comments must keep.
+func notebookSource(data []byte, outputs string) ([]byte, string, map[int]bool, error) {
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, "", nil, err
+	}
+	lang := strings.ToLower(nb.Metadata.LanguageInfo.Name)
+	if lang == "" {
+		lang = strings.ToLower(nb.Metadata.Kernelspec.Language)
+	}
+	if l, ok := notebookLanguages[lang]; ok {
+		lang = l
+	}
+	if lang == "" {
+		lang = "python"
+	}
+	comment := "#"
+	switch lang {
+	case "c", "cpp", "csharp", "java", "javascript", "typescript", "scala", "kotlin", "go", "rust", "swift":
+		comment = "//"
+	}
+
+	var b strings.Builder
+	line := 0
+	protected := map[int]bool{}
+	// note writes a line of notebook content.
+	note := func(text string) {
+		line++
+		protected[line] = true
+		b.WriteString(text + "\n")
+	}
+	commented := func(text string) {
+		for _, ln := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			if ln = strings.TrimRight(ln, " \t\r"); ln == "" {
+				note(comment)
+			} else {
+				note(comment + " " + ln)
+			}
+		}
+	}
+	for i, cell := range nb.Cells {
+		if i > 0 {
+			line++
+			b.WriteString("\n")
+		}
+		if cell.CellType != "code" {
+			note(fmt.Sprintf("%s %%%% [%s]", comment, cell.CellType))
+			commented(string(cell.Source))
+			continue
+		}
+		note(comment + " %%")
+		if src := strings.TrimRight(string(cell.Source), "\n"); src != "" {
+			line += strings.Count(src, "\n") + 1
+			b.WriteString(src + "\n")
+		}
+		if outputs != "truncate" || len(cell.Outputs) == 0 {
+			continue
+		}
+		note(comment + " Output:")
+		for _, out := range cell.Outputs {
+			commented(out.text())
+		}
+	}
+	return []byte(b.String()), lang, protected, nil
+}
+
+// analyzedSource returns the source of the file at path as analyzers see
+// it: a notebook is read as the script of its cells.
+func analyzedSource(path string, cfg *Config) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".ipynb") {
+		if src, _, _, err := notebookSource(data, cfg.NotebookOutputs); err == nil {
+			return src, nil
+		}
+	}
+	return data, nil
+}
+
+// text returns the readable text of the output, cut to notebookOutputLines
+// lines. Rich data without a plain text form is named instead.
+func (o notebookOutput) text() string {
+	var text string
+	switch o.OutputType {
+	case "stream":
+		text = string(o.Text)
+	case "error":
+		return o.Ename + ": " + o.Evalue
+	default:
+		var plain notebookText
+		if raw, ok := o.Data["text/plain"]; ok && json.Unmarshal(raw, &plain) == nil {
+			text = string(plain)
+			break
+		}
+		kinds := make([]string, 0, len(o.Data))
+		for kind := range o.Data {
+			kinds = append(kinds, kind)
+		}
+		sort.Strings(kinds)
+		return fmt.Sprintf("<%s output omitted>", strings.Join(kinds, ", "))
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if len(lines) > notebookOutputLines {
+		more := len(lines) - notebookOutputLines
+		lines = append(lines[:notebookOutputLines], fmt.Sprintf("... %d more lines", more))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,582 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// cToken is a lexical token of C, C++, Java or Rust source. Kind is 'i' for
+// identifiers and keywords, 's' for string and character literals, 'n' for
+// numbers, 'l' for Rust lifetimes, '#' for C preprocessor directives (Text
+// is the whole directive) and 'p' for punctuation.
+type cToken struct {
+	Kind     byte
+	Text     string
+	Line     int
+	Off, End int
+	// Doc is the doc comment directly preceding the token, if any.
+	Doc string
+}
+
+// outline is the result of scanning one file of a brace language.
+type outline struct {
+	AST  *ASTInfo
+	Defs []defSpan
+	// Bodies are the byte offsets of the function bodies elided in
+	// skeleton mode, from the opening brace to past the closing one.
+	Bodies [][2]int
+	// Balanced is false if the braces of the file do not match, in which
+	// case the file has no skeleton.
+	Balanced bool
+}
+
+// errUnbalanced is returned for the skeleton of a file whose braces do not match.
+var errUnbalanced = errors.New("unbalanced braces")
+
+// cNotCallees are keywords that may directly precede an opening parenthesis.
+var cNotCallees = map[string]bool{
+	"if": true, "while": true, "for": true, "switch": true, "catch": true,
+	"return": true, "sizeof": true, "alignof": true, "typeof": true,
+	"decltype": true, "static_assert": true, "defined": true, "match": true,
+	"synchronized": true, "super": true, "this": true, "throw": true,
+	"delete": true, "fn": true, "noexcept": true,
+	"__attribute__": true, "__declspec": true, "alignas": true, "try": true,
+}
+
+// cTokens splits C, C++, Java or Rust source (lang is "c", "cpp", "java" or
+// "rust") into tokens, skipping comments. Doc comments are attached to the
+// token that follows them: "///" and "/**" comments for Rust and Java, any
+// comment for C and C++. Rust inner doc comments ("//!", "/*!") at the top
+// of the file are returned as the file's doc.
+func cTokens(s, lang string) ([]cToken, string) {
+	rust := lang == "rust"
+	preproc := lang == "c" || lang == "cpp"
+	var toks []cToken
+	var fileDoc []string
+	doc, docEnd := "", 0 // pending doc comment and its last line
+	line, lastLine := 1, 0
+	emit := func(kind byte, start, end int) {
+		t := cToken{Kind: kind, Text: s[start:end], Line: line, Off: start, End: end}
+		if doc != "" && line <= docEnd+1 {
+			t.Doc = doc
+		}
+		doc = ""
+		toks = append(toks, t)
+		line += strings.Count(t.Text, "\n")
+		lastLine = line
+	}
+	comment := func(text string, lineComment bool) {
+		startLine := line
+		line += strings.Count(text, "\n")
+		isDoc := true
+		switch {
+		case rust && (strings.HasPrefix(text, "//!") || strings.HasPrefix(text, "/*!")):
+			if len(toks) == 0 {
+				fileDoc = append(fileDoc, text)
+			}
+			return
+		case rust:
+			isDoc = (strings.HasPrefix(text, "///") && !strings.HasPrefix(text, "////")) ||
+				(strings.HasPrefix(text, "/**") && text != "/**/")
+		case lang == "java":
+			isDoc = strings.HasPrefix(text, "/**") && text != "/**/"
+		}
+		switch {
+		case !isDoc || startLine == lastLine:
+			// Trailing comments document the code before them.
+			doc = ""
+		case lineComment && doc != "" && startLine == docEnd+1 && strings.HasPrefix(doc, "//"):
+			doc += "\n" + text
+			docEnd = line
+		default:
+			doc, docEnd = text, line
+		}
+	}
+
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f' || c == '\v':
+			i++
+		case c == '/' && i+1 < len(s) && s[i+1] == '/':
+			j := strings.IndexByte(s[i:], '\n')
+			if j < 0 {
+				j = len(s) - i
+			}
+			comment(s[i:i+j], true)
+			i += j
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			j, depth := i+2, 1
+			for j < len(s) && depth > 0 {
+				switch {
+				case strings.HasPrefix(s[j:], "*/"):
+					depth--
+					j += 2
+				case rust && strings.HasPrefix(s[j:], "/*"):
+					depth++
+					j += 2
+				default:
+					j++
+				}
+			}
+			comment(s[i:j], false)
+			i = j
+		case preproc && c == '#' && lastLine < line:
+			j := i
+			for j < len(s) && s[j] != '\n' {
+				if s[j] == '\\' && j+1 < len(s) && (s[j+1] == '\n' || s[j+1] == '\r') {
+					j++
+				}
+				j++
+			}
+			emit('#', i, len(strings.TrimRight(s[:j], "\r\\ \t")))
+			i = j
+		case c == '"' || (c == '\'' && !rust):
+			j := cStringEnd(s, i, lang)
+			emit('s', i, j)
+			i = j
+		case c == '\'':
+			// A Rust character literal or lifetime.
+			if rustCharLiteral(s, i) {
+				j := cStringEnd(s, i, lang)
+				emit('s', i, j)
+				i = j
+				continue
+			}
+			j := i + 1
+			for j < len(s) && isCIdentByte(s[j]) {
+				j++
+			}
+			emit('l', i, j)
+			i = j
+		case isCIdentByte(c) && !(c >= '0' && c <= '9'):
+			j := i
+			for j < len(s) && isCIdentByte(s[j]) {
+				j++
+			}
+			// String prefixes: r"", r#""#, b"", br"" in Rust; u8"", L"",
+			// R"()" and the like in C++.
+			if j < len(s) && (s[j] == '"' || rust && s[j] == '#' && strings.HasSuffix(s[i:j], "r") && rustRawQuote(s, j)) && cStringPrefix(s[i:j], lang) {
+				k := cRawStringEnd(s, i, j, lang)
+				emit('s', i, k)
+				i = k
+				continue
+			}
+			emit('i', i, j)
+			i = j
+		case c >= '0' && c <= '9' || (c == '.' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i + 1
+			for j < len(s) && cNumberByte(s, j, preproc) {
+				j++
+			}
+			emit('n', i, j)
+			i = j
+		default:
+			j := i + 1
+			if i+1 < len(s) {
+				switch s[i : i+2] {
+				case "::", "->", "=>", "==", "!=", "<=", ">=", "&&", "||",
+					"+=", "-=", "*=", "/=", "%=", "|=", "^=":
+					j = i + 2
+				}
+			}
+			emit('p', i, j)
+			i = j
+		}
+	}
+	return toks, strings.Join(fileDoc, "\n")
+}
+
+// isCIdentByte reports whether c may appear in an identifier. Bytes of
+// multi-byte UTF-8 sequences are accepted so non-ASCII names stay whole.
+func isCIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+// cNumberByte reports whether s[j] continues the number literal before it:
+// digits, suffixes, hex digits, a decimal point, an exponent sign or, in C
+// and C++, a digit separator.
+func cNumberByte(s string, j int, preproc bool) bool {
+	switch c := s[j]; {
+	case isCIdentByte(c):
+		return true
+	case c == '.':
+		// Not a range (1..2) or a method call on a literal (1.max(2)).
+		return j+1 >= len(s) || s[j+1] != '.' && !unicode.IsLetter(rune(s[j+1])) && s[j+1] != '_'
+	case c == '\'':
+		return preproc && j+1 < len(s) && isCIdentByte(s[j+1])
+	case c == '+' || c == '-':
+		return strings.IndexByte("eEpP", s[j-1]) >= 0
+	}
+	return false
+}
+
+// cStringEnd returns the offset just past the string or character literal
+// starting with the quote at i. Java text blocks are delimited by """.
+func cStringEnd(s string, i int, lang string) int {
+	q := s[i]
+	if lang == "java" && strings.HasPrefix(s[i:], `"""`) {
+		if j := strings.Index(s[i+3:], `"""`); j >= 0 {
+			return i + 3 + j + 3
+		}
+		return len(s)
+	}
+	for j := i + 1; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case q:
+			return j + 1
+		case '\n':
+			// Only Rust strings span lines unescaped.
+			if lang != "rust" {
+				return j
+			}
+		}
+	}
+	return len(s)
+}
+
+// rustCharLiteral reports whether the quote at i starts a character
+// literal, '\n' or 'x', rather than a lifetime such as 'a.
+func rustCharLiteral(s string, i int) bool {
+	if i+1 >= len(s) {
+		return false
+	}
+	_, size := utf8.DecodeRuneInString(s[i+1:])
+	return s[i+1] == '\\' || (i+1+size < len(s) && s[i+1+size] == '\'')
+}
+
+// rustRawQuote reports whether the '#' at j starts the hashes of a raw
+// string, r#"..."#, rather than a raw identifier, r#type.
+func rustRawQuote(s string, j int) bool {
+	for j < len(s) && s[j] == '#' {
+		j++
+	}
+	return j < len(s) && s[j] == '"'
+}
+
+// cStringPrefix reports whether prefix, directly followed by a quote (or a
+// '#' for Rust raw strings), is a string literal prefix.
+func cStringPrefix(prefix, lang string) bool {
+	switch lang {
+	case "rust":
+		return prefix == "r" || prefix == "b" || prefix == "br" || prefix == "c" || prefix == "cr"
+	case "c", "cpp":
+		switch prefix {
+		case "L", "u", "U", "u8", "R", "LR", "uR", "UR", "u8R":
+			return true
+		}
+	}
+	return false
+}
+
+// cRawStringEnd returns the offset just past the prefixed string literal
+// that starts at i, whose prefix ends at j.
+func cRawStringEnd(s string, i, j int, lang string) int {
+	prefix := s[i:j]
+	switch {
+	case lang == "rust" && strings.HasSuffix(prefix, "r"):
+		// r"...", r#"..."#, r##"..."##
+		k := j
+		for k < len(s) && s[k] == '#' {
+			k++
+		}
+		closing := `"` + strings.Repeat("#", k-j)
+		if e := strings.Index(s[k+1:], closing); k < len(s) && e >= 0 {
+			return k + 1 + e + len(closing)
+		}
+		return len(s)
+	case strings.HasSuffix(prefix, "R"):
+		// R"delim(...)delim"
+		open := strings.IndexByte(s[j:], '(')
+		if open < 0 {
+			return cStringEnd(s, j, lang)
+		}
+		closing := ")" + s[j+1:j+open] + `"`
+		if e := strings.Index(s[j+open:], closing); e >= 0 {
+			return j + open + e + len(closing)
+		}
+		return len(s)
+	}
+	return cStringEnd(s, j, lang)
+}
+
+// cDocText strips the comment markers from a doc comment.
+func cDocText(doc string) string {
+	if doc == "" {
+		return ""
+	}
+	lines := strings.Split(doc, "\n")
+	for i, ln := range lines {
+		ln = strings.TrimSpace(ln)
+		for _, p := range []string{"///", "//!", "//", "/**", "/*!", "/*"} {
+			if strings.HasPrefix(ln, p) {
+				ln = ln[len(p):]
+				break
+			}
+		}
+		ln = strings.TrimSuffix(ln, "*/")
+		lines[i] = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(ln), "*"))
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// outlineParser holds the state shared by the brace-language scanners.
+type outlineParser struct {
+	src      string
+	toks     []cToken
+	withDocs bool
+	out      *outline
+}
+
+func newOutlineParser(src []byte, lang string, withDocs bool) (*outlineParser, string) {
+	toks, fileDoc := cTokens(string(src), lang)
+	p := &outlineParser{
+		src:      string(src),
+		toks:     toks,
+		withDocs: withDocs,
+		out: &outline{
+			AST:      &ASTInfo{Imports: []string{}, Structs: []StructInfo{}, Functions: []FuncInfo{}},
+			Balanced: cBalanced(toks),
+		},
+	}
+	return p, fileDoc
+}
+
+// cBalanced reports whether the braces of toks match.
+func cBalanced(toks []cToken) bool {
+	depth := 0
+	for _, t := range toks {
+		if t.Kind != 'p' {
+			continue
+		}
+		switch t.Text {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// tok returns the token at i, or a zero token if i is out of range.
+func (p *outlineParser) tok(i int) cToken {
+	if i < 0 || i >= len(p.toks) {
+		return cToken{}
+	}
+	return p.toks[i]
+}
+
+// is reports whether the token at i is the punctuation or word text.
+func (p *outlineParser) is(i int, text string) bool {
+	t := p.tok(i)
+	return (t.Kind == 'p' || t.Kind == 'i') && t.Text == text
+}
+
+// ident returns the text of the token at i if it is an identifier.
+func (p *outlineParser) ident(i int) string {
+	if t := p.tok(i); t.Kind == 'i' {
+		return t.Text
+	}
+	return ""
+}
+
+// text returns the source from token from to token to, inclusive, with its
+// whitespace collapsed.
+func (p *outlineParser) text(from, to int) string {
+	if from > to || from < 0 || to >= len(p.toks) {
+		return ""
+	}
+	return compactSignature(p.src[p.toks[from].Off:p.toks[to].End])
+}
+
+// doc returns the doc comment of the token at i if docs are wanted.
+func (p *outlineParser) doc(i int) string {
+	if !p.withDocs {
+		return ""
+	}
+	return cDocText(p.tok(i).Doc)
+}
+
+// match returns the index of the bracket closing the one at i.
+func (p *outlineParser) match(i int) int {
+	depth := 0
+	for j := i; j < len(p.toks); j++ {
+		if p.toks[j].Kind != 'p' {
+			continue
+		}
+		switch p.toks[j].Text {
+		case "(", "[", "{":
+			depth++
+		case ")", "]", "}":
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return len(p.toks) - 1
+}
+
+// angleEnd returns the index of the '>' closing the '<' at i, or i if the
+// angle brackets do not close before a brace or semicolon.
+func (p *outlineParser) angleEnd(i int) int {
+	depth := 0
+	for j := i; j < len(p.toks); j++ {
+		switch {
+		case p.is(j, "<"):
+			depth++
+		case p.is(j, ">"):
+			depth--
+			if depth == 0 {
+				return j
+			}
+		case p.is(j, "("), p.is(j, "["):
+			j = p.match(j)
+		case p.is(j, "{"), p.is(j, ";"), p.is(j, "}"):
+			return i
+		}
+	}
+	return i
+}
+
+// stmtEnd returns the index of the ';' or '{' ending the declaration that
+// starts at i, or of the '}' closing the enclosing block. Brackets are
+// skipped, as are braces that follow '=' (initializers) or '::' (Rust use
+// groups). In C++, braces of a constructor's member initializer list are
+// skipped too.
+func (p *outlineParser) stmtEnd(i int, cpp bool) int {
+	assigned, afterParams, initList := false, false, false
+	for j := i; j < len(p.toks); j++ {
+		t := p.toks[j]
+		if t.Kind != 'p' {
+			continue
+		}
+		switch t.Text {
+		case "(", "[":
+			j = p.match(j)
+			if t.Text == "(" && !assigned {
+				afterParams = true
+			}
+		case "=":
+			assigned = true
+		case ":":
+			if cpp && afterParams && !assigned {
+				initList = true
+			}
+		case "{":
+			prev := p.tok(j - 1)
+			if assigned || p.is(j-1, "::") || (initList && (prev.Kind == 'i' || p.is(j-1, ">"))) {
+				j = p.match(j)
+				continue
+			}
+			return j
+		case ";", "}":
+			return j
+		}
+	}
+	return len(p.toks)
+}
+
+// splitTopLevel returns the [from, to] token ranges between from and to,
+// inclusive, separated by sep outside of brackets, including angle brackets.
+func (p *outlineParser) splitTopLevel(from, to int, sep string) [][2]int {
+	var parts [][2]int
+	start, angle := from, 0
+	for j := from; j <= to; j++ {
+		switch {
+		case p.is(j, "("), p.is(j, "["), p.is(j, "{"):
+			j = p.match(j)
+		case p.is(j, "<"):
+			angle++
+		case p.is(j, ">") && angle > 0:
+			angle--
+		case p.is(j, sep) && angle == 0:
+			if j > start {
+				parts = append(parts, [2]int{start, j - 1})
+			}
+			start = j + 1
+		}
+	}
+	if start <= to {
+		parts = append(parts, [2]int{start, to})
+	}
+	return parts
+}
+
+// addDef records a declaration spanning tokens first to last and returns its index.
+func (p *outlineParser) addDef(key, kind string, first, last int) int {
+	p.out.Defs = append(p.out.Defs, defSpan{Key: key, Kind: kind, Start: p.tok(first).Line, End: p.tok(last).Line})
+	return len(p.out.Defs) - 1
+}
+
+// addBody records the function body between the braces at open and close
+// for def: the calls made in it and its offsets for skeleton mode. Bodies
+// are found in source order; one that overlaps the previous body, as when
+// preprocessor conditionals unbalance brackets, is not elided.
+func (p *outlineParser) addBody(def, open, close int, class string) {
+	p.out.Defs[def].End = p.tok(close).Line
+	p.out.Defs[def].Calls = append(p.out.Defs[def].Calls, p.calls(open+1, close-1, class)...)
+	if n := len(p.out.Bodies); n > 0 && p.out.Bodies[n-1][1] > p.toks[open].Off {
+		return
+	}
+	p.out.Bodies = append(p.out.Bodies, [2]int{p.toks[open].Off, p.toks[close].End})
+}
+
+// calls returns the callee names of the calls between tokens from and to.
+// Calls through self, this or Self resolve to "Class.name", calls qualified
+// with a capitalized type name to "Type.name"; other calls are reduced to
+// their name. Macro invocations are skipped.
+func (p *outlineParser) calls(from, to int, class string) []string {
+	var calls []string
+	for i := from; i <= to; i++ {
+		name := p.ident(i)
+		if name == "" || cNotCallees[name] {
+			continue
+		}
+		next := i + 1
+		if p.is(next, "::") && p.is(next+1, "<") {
+			// Rust turbofish: name::<T>(...)
+			next = p.angleEnd(next+1) + 1
+		}
+		if !p.is(next, "(") {
+			continue
+		}
+		switch prev := p.tok(i - 1).Text; {
+		case prev == "." || prev == "->" || prev == "::":
+			q := p.ident(i - 2)
+			switch {
+			case q == "self" || q == "this" || q == "Self":
+				if class != "" {
+					calls = append(calls, class+"."+name)
+					continue
+				}
+			case q != "" && unicode.IsUpper(rune(q[0])) && !p.is(i-3, ".") && !p.is(i-3, "->"):
+				calls = append(calls, q+"."+name)
+				continue
+			}
+		case prev == "fn" || prev == "!":
+			continue
+		}
+		calls = append(calls, name)
+	}
+	return calls
+}
+
+// skeleton returns the file with its function bodies elided.
+func (o *outline) skeleton(src []byte) ([]sourceLine, error) {
+	if !o.Balanced {
+		return nil, errUnbalanced
+	}
+	return elideBodies(string(src), o.Bodies), nil
+}
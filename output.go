@@ -0,0 +1,611 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OutputWriter renders a Context incrementally. The header is written once,
+// each file is written as soon as its content is available, and the footer
+// closes the document. Implementations must not retain file contents so that
+// memory usage stays bounded by the largest single file.
+type OutputWriter interface {
+	WriteHeader(ctx *Context) error
+	WriteFile(f *FileInfo) error
+	WriteFooter(ctx *Context) error
+}
+
+// newOutputWriter returns the OutputWriter for the configured format writing to w.
+func newOutputWriter(w io.Writer, cfg *Config) (OutputWriter, error) {
+	switch strings.ToLower(cfg.Format) {
+	case "json":
+		return &jsonWriter{w: w}, nil
+	case "jsonl", "ndjson":
+		return &jsonlWriter{enc: json.NewEncoder(w)}, nil
+	case "yaml", "yml":
+		return &yamlWriter{w: w}, nil
+	case "markdown", "md":
+		return &markdownWriter{w: w, reproducible: cfg.Reproducible}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", cfg.Format)
+	}
+}
+
+// outputExtension returns the default file extension for format.
+func outputExtension(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "json":
+		return "json", nil
+	case "jsonl", "ndjson":
+		return "jsonl", nil
+	case "yaml", "yml":
+		return "yaml", nil
+	case "markdown", "md":
+		return "md", nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// writeOutput streams ctx to w in the configured format. File contents are
+// loaded one at a time, so in streaming mode only the file being written is
+// held in memory.
+func writeOutput(w io.Writer, ctx *Context, cfg *Config) error {
+	ow, err := newOutputWriter(w, cfg)
+	if err != nil {
+		return err
+	}
+	if err := ow.WriteHeader(ctx); err != nil {
+		return err
+	}
+	for _, f := range outputOrder(ctx.Files, cfg.Format) {
+		content, err := loadContent(&f, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to reload %s: %v\n", f.Path, err)
+			content = fmt.Sprintf("<failed to read file: %v>", err)
+		}
+		f.Content = content
+		if err := ow.WriteFile(&f); err != nil {
+			return err
+		}
+	}
+	return ow.WriteFooter(ctx)
+}
+
+// outputOrder returns files in the order they are emitted for format.
+// Markdown groups files by language; other formats keep the context order.
+func outputOrder(files []FileInfo, format string) []FileInfo {
+	ordered := make([]FileInfo, len(files))
+	copy(ordered, files)
+	switch strings.ToLower(format) {
+	case "markdown", "md":
+		sort.SliceStable(ordered, func(i, j int) bool {
+			if ordered[i].Language != ordered[j].Language {
+				return ordered[i].Language < ordered[j].Language
+			}
+			return ordered[i].Path < ordered[j].Path
+		})
+	}
+	return ordered
+}
+
+// loadContent returns the rendered content of f. In streaming mode contents
+// are dropped after the first pass, so the file is read and processed again.
+func loadContent(f *FileInfo, cfg *Config) (string, error) {
+	if !cfg.Stream {
+		return f.Content, nil
+	}
+	fi, err := processFile(filepath.Join(cfg.Path, f.Path), cfg, f.Ranges)
+	if err != nil {
+		return "", err
+	}
+	return fi.Content, nil
+}
+
+// jsonWriter emits the same document as json.MarshalIndent(ctx, "", "  "),
+// writing the files array one element at a time.
+type jsonWriter struct {
+	w    io.Writer
+	n    int
+	tail []byte
+}
+
+func (jw *jsonWriter) WriteHeader(ctx *Context) error {
+	// Marshal the context without files and split it around the empty
+	// files array; the top-level key is the only one indented by two spaces.
+	shell := *ctx
+	shell.Files = []FileInfo{}
+	b, err := json.MarshalIndent(&shell, "", "  ")
+	if err != nil {
+		return err
+	}
+	marker := []byte("\n  \"files\": [")
+	idx := bytes.Index(b, marker)
+	if idx < 0 {
+		return fmt.Errorf("json writer: files array not found")
+	}
+	head := idx + len(marker)
+	jw.tail = b[head+1:] // skip the closing bracket of the empty array
+	_, err = jw.w.Write(b[:head])
+	return err
+}
+
+func (jw *jsonWriter) WriteFile(f *FileInfo) error {
+	b, err := json.MarshalIndent(f, "    ", "  ")
+	if err != nil {
+		return err
+	}
+	sep := ",\n    "
+	if jw.n == 0 {
+		sep = "\n    "
+	}
+	jw.n++
+	if _, err := io.WriteString(jw.w, sep); err != nil {
+		return err
+	}
+	_, err = jw.w.Write(b)
+	return err
+}
+
+func (jw *jsonWriter) WriteFooter(ctx *Context) error {
+	closing := "]"
+	if jw.n > 0 {
+		closing = "\n  ]"
+	}
+	if _, err := io.WriteString(jw.w, closing); err != nil {
+		return err
+	}
+	_, err := jw.w.Write(jw.tail)
+	return err
+}
+
+// jsonlWriter emits one compact JSON object per line: a header record with
+// the tree and project stats followed by one record per file.
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+// jsonlHeader is the first JSONL record. The Files field shadows the embedded
+// context's files so that they are emitted as separate records instead.
+type jsonlHeader struct {
+	Type string `json:"type"`
+	*Context
+	Files *struct{} `json:"files,omitempty"`
+}
+
+// jsonlFile is a per-file JSONL record.
+type jsonlFile struct {
+	Type string `json:"type"`
+	*FileInfo
+}
+
+func (lw *jsonlWriter) WriteHeader(ctx *Context) error {
+	return lw.enc.Encode(jsonlHeader{Type: "header", Context: ctx})
+}
+
+func (lw *jsonlWriter) WriteFile(f *FileInfo) error {
+	return lw.enc.Encode(jsonlFile{Type: "file", FileInfo: f})
+}
+
+func (lw *jsonlWriter) WriteFooter(ctx *Context) error {
+	return nil
+}
+
+// yamlWriter emits the same document as yaml.Marshal(ctx), writing the
+// files sequence one item at a time.
+type yamlWriter struct {
+	w    io.Writer
+	n    int
+	tail []byte
+}
+
+func (yw *yamlWriter) WriteHeader(ctx *Context) error {
+	shell := *ctx
+	shell.Files = []FileInfo{}
+	b, err := yaml.Marshal(&shell)
+	if err != nil {
+		return err
+	}
+	marker := []byte("\nfiles: []\n")
+	idx := bytes.Index(b, marker)
+	if idx < 0 {
+		return fmt.Errorf("yaml writer: files sequence not found")
+	}
+	yw.tail = b[idx+len(marker):]
+	_, err = yw.w.Write(b[:idx+1])
+	return err
+}
+
+func (yw *yamlWriter) WriteFile(f *FileInfo) error {
+	b, err := yaml.Marshal([]FileInfo{*f})
+	if err != nil {
+		return err
+	}
+	if yw.n == 0 {
+		if _, err := io.WriteString(yw.w, "files:\n"); err != nil {
+			return err
+		}
+	}
+	yw.n++
+	// Nest the single-item sequence under the files key. Empty lines inside
+	// block scalars are left unindented, as yaml.Marshal does.
+	lines := strings.SplitAfter(string(b), "\n")
+	for _, ln := range lines {
+		if ln != "" && ln != "\n" {
+			ln = "    " + ln
+		}
+		if _, err := io.WriteString(yw.w, ln); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (yw *yamlWriter) WriteFooter(ctx *Context) error {
+	if yw.n == 0 {
+		if _, err := io.WriteString(yw.w, "files: []\n"); err != nil {
+			return err
+		}
+	}
+	_, err := yw.w.Write(yw.tail)
+	return err
+}
+
+// markdownWriter creates a human-friendly markdown summary containing
+// the directory tree, per-file sections (AST summary if available), and content blocks.
+// Files must arrive grouped by language (see outputOrder).
+type markdownWriter struct {
+	w            io.Writer
+	reproducible bool
+	lang         string
+	// indexed is set when the header lists packages, making the per-file
+	// package line redundant.
+	indexed bool
+}
+
+func (mw *markdownWriter) WriteHeader(ctx *Context) error {
+	var b strings.Builder
+	b.WriteString("# Project Context (Contextify)\n\n")
+	b.WriteString(fmt.Sprintf("**Project Path:** `%s`\n\n", ctx.ProjectPath))
+	b.WriteString(fmt.Sprintf("**Total Files:** %d\n\n", ctx.TotalFiles))
+	b.WriteString(fmt.Sprintf("**Total Size:** %d bytes\n\n", ctx.TotalSize))
+	b.WriteString(fmt.Sprintf("**Estimated Tokens:** %d\n\n", ctx.EstimatedTokens))
+	if ctx.Truncated {
+		b.WriteString("> **Note:** context was truncated to satisfy token limits.\n\n")
+	}
+	if md := ctx.Metadata; md != nil {
+		b.WriteString("## Provenance\n\n")
+		b.WriteString(fmt.Sprintf("- Contextify Version: `%s`\n", md.Version))
+		if md.Git != nil {
+			dirty := ""
+			if md.Git.Dirty {
+				dirty = " (dirty)"
+			}
+			b.WriteString(fmt.Sprintf("- Git Commit: `%s`%s\n", md.Git.Commit, dirty))
+		}
+		b.WriteString(fmt.Sprintf("- Tokenizer: `%s`\n\n", md.Tokenizer))
+		cfgYAML, err := yaml.Marshal(&md.Config)
+		if err != nil {
+			return err
+		}
+		b.WriteString("<details><summary>Effective configuration</summary>\n\n")
+		b.WriteString("```yaml\n")
+		b.Write(cfgYAML)
+		b.WriteString("```\n\n</details>\n\n")
+	}
+	if len(ctx.Packages) > 0 {
+		mw.indexed = true
+		b.WriteString("## Packages\n\n")
+		b.WriteString("| Package | Files | Exported API | Internal Imports |\n")
+		b.WriteString("|---|---|---|---|\n")
+		for _, pkg := range ctx.Packages {
+			b.WriteString(fmt.Sprintf("| `%s` (`%s`) | %s | %s | %s |\n",
+				pkg.ImportPath, pkg.Name, codeList(pkg.Files), codeList(pkg.Exported), codeList(pkg.Imports)))
+		}
+		b.WriteString("\n")
+	}
+	if g := ctx.Graph; g != nil {
+		if g.Packages != "" {
+			b.WriteString("## Package Dependency Graph\n\n")
+			b.WriteString(fmt.Sprintf("```%s\n%s```\n\n", g.Format, g.Packages))
+		}
+		if g.Calls != "" {
+			b.WriteString("## Focused Call Graph\n\n")
+			b.WriteString(fmt.Sprintf("```%s\n%s```\n\n", g.Format, g.Calls))
+		}
+	}
+	b.WriteString("## Directory Structure\n\n")
+	b.WriteString("```\n")
+	b.WriteString(ctx.TreeStructure)
+	b.WriteString("```\n\n")
+	_, err := io.WriteString(mw.w, b.String())
+	return err
+}
+
+func (mw *markdownWriter) WriteFile(f *FileInfo) error {
+	var b strings.Builder
+	// Start a new section whenever the language changes.
+	if f.Language != mw.lang {
+		mw.lang = f.Language
+		b.WriteString(fmt.Sprintf("### %s Files\n\n", strings.Title(f.Language)))
+	}
+	var notes []string
+	if f.Generated {
+		notes = append(notes, "generated")
+	}
+	switch {
+	case f.Skeleton:
+		notes = append(notes, "skeleton")
+	case len(f.Ranges) > 0:
+		spans := make([]string, len(f.Ranges))
+		for i, r := range f.Ranges {
+			spans[i] = fmt.Sprintf("%d-%d", r.Start, r.End)
+		}
+		notes = append(notes, "lines "+strings.Join(spans, ", "))
+	}
+	if len(notes) > 0 {
+		b.WriteString(fmt.Sprintf("#### `%s` — %d bytes (%s)\n\n", f.Path, f.Size, strings.Join(notes, "; ")))
+	} else {
+		b.WriteString(fmt.Sprintf("#### `%s` — %d bytes\n\n", f.Path, f.Size))
+	}
+	if f.SHA256 != "" {
+		b.WriteString(fmt.Sprintf("SHA-256: `%s`\n\n", f.SHA256))
+	}
+	if f.AST != nil {
+		writeASTSummary(&b, f.AST, !mw.indexed)
+	}
+
+	blockLang := f.Language
+	if blockLang == "plaintext" {
+		blockLang = ""
+	}
+	b.WriteString(fmt.Sprintf("```%s\n", blockLang))
+	b.WriteString(f.Content)
+	if !strings.HasSuffix(f.Content, "\n") {
+		b.WriteString("\n")
+	}
+	b.WriteString("```\n\n")
+	_, err := io.WriteString(mw.w, b.String())
+	return err
+}
+
+// codeList renders items as comma-separated inline code, or "-" if empty.
+func codeList(items []string) string {
+	if len(items) == 0 {
+		return "-"
+	}
+	return "`" + strings.Join(items, "`, `") + "`"
+}
+
+// writeASTSummary renders an AST summary as a markdown list. The package
+// line is omitted when the package index already covers it.
+func writeASTSummary(b *strings.Builder, ai *ASTInfo, withPackage bool) {
+	b.WriteString("**AST Summary:**\n\n")
+	if withPackage && ai.Package != "" {
+		b.WriteString(fmt.Sprintf("- Package: `%s`\n", ai.Package))
+	}
+	if ai.Doc != "" {
+		b.WriteString(fmt.Sprintf("- Doc: %s\n", docSummary(ai.Doc)))
+	}
+	if len(ai.Imports) > 0 {
+		b.WriteString(fmt.Sprintf("- Imports: `%s`\n", strings.Join(ai.Imports, ", ")))
+	}
+	if len(ai.Modules) > 0 {
+		b.WriteString(fmt.Sprintf("- Modules: `%s`\n", strings.Join(ai.Modules, ", ")))
+	}
+	if len(ai.Exports) > 0 {
+		b.WriteString(fmt.Sprintf("- Exports: `%s`\n", strings.Join(ai.Exports, ", ")))
+	}
+	if len(ai.Structs) > 0 {
+		b.WriteString("- Structs:\n")
+		for _, st := range ai.Structs {
+			b.WriteString(fmt.Sprintf("  - `%s%s`%s\n", st.Name, st.TypeParams, docSuffix(st.Doc)))
+			if len(st.Fields) > 0 {
+				fields := make([]string, len(st.Fields))
+				for i, fd := range st.Fields {
+					fields[i] = fd.Name + " " + fd.Type
+					if fd.Embedded {
+						fields[i] = fd.Type
+					}
+					if fd.Tag != "" {
+						fields[i] += " " + fd.Tag
+					}
+				}
+				b.WriteString(fmt.Sprintf("    - Fields: `%s`\n", strings.Join(fields, "`, `")))
+			}
+			if len(st.Methods) > 0 {
+				b.WriteString(fmt.Sprintf("    - Methods: `%s`\n", strings.Join(st.Methods, "`, `")))
+			}
+		}
+	}
+	listed := map[int]bool{}
+	if len(ai.Classes) > 0 {
+		b.WriteString("- Classes:\n")
+		for _, c := range ai.Classes {
+			decl := c.Name
+			if c.Kind != "" {
+				decl = c.Kind + " " + decl
+			}
+			if len(c.Bases) > 0 {
+				decl += "(" + strings.Join(c.Bases, ", ") + ")"
+			}
+			b.WriteString(fmt.Sprintf("  - %s`%s`%s\n", decoratorPrefix(c.Decorators), decl, docSuffix(c.Doc)))
+			if len(c.Fields) > 0 {
+				b.WriteString(fmt.Sprintf("    - Fields: `%s`\n", strings.Join(c.Fields, "`, `")))
+			}
+			writeMethods(b, ai, c.Name, c.Methods, listed)
+		}
+	}
+	if len(ai.Interfaces) > 0 {
+		b.WriteString("- Interfaces:\n")
+		for _, it := range ai.Interfaces {
+			fns, detailed := methodFuncs(ai, it.Name, it.Methods, listed)
+			members := it.Embeds
+			if !detailed {
+				members = append(append([]string{}, it.Embeds...), it.Methods...)
+			}
+			b.WriteString(fmt.Sprintf("  - `%s%s`", it.Name, it.TypeParams))
+			if len(members) > 0 {
+				b.WriteString(fmt.Sprintf(": `%s`", strings.Join(members, "; ")))
+			}
+			b.WriteString(docSuffix(it.Doc) + "\n")
+			if detailed {
+				writeMethodList(b, it.Methods, fns)
+			}
+		}
+	}
+	if len(ai.Impls) > 0 {
+		b.WriteString("- Impls:\n")
+		for _, im := range ai.Impls {
+			decl := "impl" + im.TypeParams + " " + im.Type
+			if im.Trait != "" {
+				decl = "impl" + im.TypeParams + " " + im.Trait + " for " + im.Type
+			}
+			b.WriteString(fmt.Sprintf("  - `%s`\n", decl))
+			writeMethods(b, ai, rustTypeName(im.Type), im.Methods, listed)
+		}
+	}
+	if len(ai.Types) > 0 {
+		b.WriteString("- Types:\n")
+		for _, t := range ai.Types {
+			decl := fmt.Sprintf("%s%s %s", t.Name, t.TypeParams, t.Type)
+			if t.Alias {
+				decl = fmt.Sprintf("%s%s = %s", t.Name, t.TypeParams, t.Type)
+			}
+			b.WriteString(fmt.Sprintf("  - `%s`%s\n", decl, docSuffix(t.Doc)))
+		}
+	}
+	if len(ai.Consts) > 0 {
+		b.WriteString(fmt.Sprintf("- Consts: `%s`\n", strings.Join(valueDecls(ai.Consts), "`, `")))
+	}
+	if len(ai.Vars) > 0 {
+		b.WriteString(fmt.Sprintf("- Vars: `%s`\n", strings.Join(valueDecls(ai.Vars), "`, `")))
+	}
+	if len(ai.Functions) > len(listed) {
+		b.WriteString("- Functions:\n")
+		for i, fn := range ai.Functions {
+			if !listed[i] {
+				b.WriteString(fmt.Sprintf("  - %s`%s`%s\n", decoratorPrefix(fn.Decorators), fn.Signature, docSuffix(fn.Doc)))
+			}
+		}
+	}
+	if len(ai.Components) > 0 {
+		b.WriteString(fmt.Sprintf("- Components: `%s`\n", strings.Join(ai.Components, "`, `")))
+	}
+	b.WriteString("\n")
+}
+
+// methodFuncs pairs the methods listed under a class, impl or interface
+// with the functions they were also recorded as, the first unlisted ones
+// of owner with the same signature, and marks those functions listed so
+// they are not repeated among the functions. It reports whether any has
+// decorators or a doc.
+func methodFuncs(ai *ASTInfo, owner string, methods []string, listed map[int]bool) ([]*FuncInfo, bool) {
+	fns := make([]*FuncInfo, len(methods))
+	detailed := false
+	for i, m := range methods {
+		for j := range ai.Functions {
+			fn := &ai.Functions[j]
+			// Python lists methods without their "def" keyword.
+			if listed[j] || fn.Receiver != owner || (fn.Signature != m && !strings.HasSuffix(fn.Signature, "def "+m)) {
+				continue
+			}
+			listed[j], fns[i] = true, fn
+			detailed = detailed || fn.Doc != "" || len(fn.Decorators) > 0
+			break
+		}
+	}
+	return fns, detailed
+}
+
+// writeMethods renders methods under their class or impl, joined on one
+// line unless any has decorators or a doc to show.
+func writeMethods(b *strings.Builder, ai *ASTInfo, owner string, methods []string, listed map[int]bool) {
+	if len(methods) == 0 {
+		return
+	}
+	fns, detailed := methodFuncs(ai, owner, methods, listed)
+	if !detailed {
+		b.WriteString(fmt.Sprintf("    - Methods: `%s`\n", strings.Join(methods, "`, `")))
+		return
+	}
+	writeMethodList(b, methods, fns)
+}
+
+// writeMethodList renders methods one per line with the decorators and doc
+// of their functions.
+func writeMethodList(b *strings.Builder, methods []string, fns []*FuncInfo) {
+	b.WriteString("    - Methods:\n")
+	for i, m := range methods {
+		var decorators []string
+		var doc string
+		if fns[i] != nil {
+			decorators, doc = fns[i].Decorators, fns[i].Doc
+		}
+		b.WriteString(fmt.Sprintf("      - %s`%s`%s\n", decoratorPrefix(decorators), m, docSuffix(doc)))
+	}
+}
+
+// decoratorPrefix renders decorators to precede a class or function.
+// Rust attributes are kept in their "#[...]" form.
+func decoratorPrefix(decorators []string) string {
+	var b strings.Builder
+	for _, d := range decorators {
+		if !strings.HasPrefix(d, "#[") {
+			d = "@" + d
+		}
+		b.WriteString("`" + d + "` ")
+	}
+	return b.String()
+}
+
+// docSummary collapses the first paragraph of a doc comment onto one line.
+func docSummary(doc string) string {
+	if i := strings.Index(doc, "\n\n"); i >= 0 {
+		doc = doc[:i]
+	}
+	return strings.Join(strings.Fields(doc), " ")
+}
+
+// docSuffix renders a doc comment summary to follow a list item, if any.
+func docSuffix(doc string) string {
+	if doc == "" {
+		return ""
+	}
+	return " — " + docSummary(doc)
+}
+
+// valueDecls renders consts or vars as "Name Type = Value".
+func valueDecls(values []ValueInfo) []string {
+	out := make([]string, len(values))
+	for i, v := range values {
+		decl := v.Name
+		if v.Type != "" {
+			decl += " " + v.Type
+		}
+		if v.Value != "" {
+			decl += " = " + v.Value
+		}
+		out[i] = decl
+	}
+	return out
+}
+
+func (mw *markdownWriter) WriteFooter(ctx *Context) error {
+	// Footer with generation timestamp, omitted for reproducible output.
+	if mw.reproducible {
+		_, err := io.WriteString(mw.w, "_Generated by Contextify_\n")
+		return err
+	}
+	_, err := fmt.Fprintf(mw.w, "_Generated by Contextify on %s_\n", time.Now().UTC().Format(time.RFC3339))
+	return err
+}
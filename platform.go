@@ -0,0 +1,182 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"go/build"
+	"go/build/constraint"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// knownOS and knownArch list the GOOS and GOARCH values recognized in Go
+// file name suffixes such as _windows.go or _linux_arm64.go.
+var knownOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true,
+	"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+	"js": true, "linux": true, "nacl": true, "netbsd": true,
+	"openbsd": true, "plan9": true, "solaris": true, "wasip1": true,
+	"windows": true, "zos": true,
+}
+
+var knownArch = map[string]bool{
+	"386": true, "amd64": true, "amd64p32": true, "arm": true,
+	"armbe": true, "arm64": true, "arm64be": true, "loong64": true,
+	"mips": true, "mipsle": true, "mips64": true, "mips64le": true,
+	"mips64p32": true, "mips64p32le": true, "ppc": true, "ppc64": true,
+	"ppc64le": true, "riscv": true, "riscv64": true, "s390": true,
+	"s390x": true, "sparc": true, "sparc64": true, "wasm": true,
+}
+
+// unixOS lists the GOOS values satisfying the "unix" build tag.
+var unixOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true,
+	"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+	"linux": true, "netbsd": true, "openbsd": true, "solaris": true,
+}
+
+// platformFilterEnabled reports whether Go files are selected for a target platform.
+func platformFilterEnabled(cfg *Config) bool {
+	return cfg.GOOS != "" || cfg.GOARCH != "" || len(cfg.Tags) > 0
+}
+
+// goFileMatchesPlatform reports whether the Go file relPath with content src
+// would be built for the configured GOOS, GOARCH and tags, considering both
+// its file name suffix and its //go:build (or // +build) constraints.
+// Unset GOOS and GOARCH default to the host, as with go build.
+func goFileMatchesPlatform(relPath string, src []byte, cfg *Config) bool {
+	goos, goarch := cfg.GOOS, cfg.GOARCH
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+	tags := map[string]bool{}
+	for _, t := range cfg.Tags {
+		tags[t] = true
+	}
+	ok := func(tag string) bool {
+		switch {
+		case tag == goos || tag == goarch || tags[tag]:
+			return true
+		case tag == "unix":
+			return unixOS[goos]
+		case tag == "linux":
+			return goos == "android"
+		case tag == "solaris":
+			return goos == "illumos"
+		case tag == "darwin":
+			return goos == "ios"
+		case tag == "gc":
+			return true
+		case tag == "cgo":
+			return build.Default.CgoEnabled
+		case strings.HasPrefix(tag, "go1."):
+			// Release tags are assumed satisfied by the current toolchain.
+			return true
+		}
+		return false
+	}
+
+	if !goodOSArchFile(filepath.Base(relPath), ok) {
+		return false
+	}
+	for _, expr := range buildConstraints(src) {
+		if !expr.Eval(ok) {
+			return false
+		}
+	}
+	return true
+}
+
+// goodOSArchFile applies the go/build file name rules: a trailing _GOOS,
+// _GOARCH or _GOOS_GOARCH element (before any _test suffix) constrains the file.
+func goodOSArchFile(name string, ok func(string) bool) bool {
+	name = strings.TrimSuffix(name, ".go")
+	name = strings.TrimSuffix(name, "_test")
+	i := strings.Index(name, "_")
+	if i < 0 {
+		return true
+	}
+	l := strings.Split(name[i:], "_")
+	n := len(l)
+	if n >= 2 && knownOS[l[n-2]] && knownArch[l[n-1]] {
+		return ok(l[n-2]) && ok(l[n-1])
+	}
+	if n >= 1 && (knownOS[l[n-1]] || knownArch[l[n-1]]) {
+		return ok(l[n-1])
+	}
+	return true
+}
+
+// buildConstraints parses the build constraints in the header of a Go file,
+// following go/build: the header is the leading run of blank lines and //
+// and /* */ comments. A //go:build line counts anywhere in it, outside
+// /* */ comments, and takes precedence over legacy // +build lines, which
+// only count if a blank line follows them before the end of the header.
+func buildConstraints(src []byte) []constraint.Expr {
+	var goBuild, plusBuild []constraint.Expr
+	var plusEnds []int // the offset of the end of each // +build line
+	end, offset := 0, 0
+	// ended is set by the first line not starting with "//", after which
+	// blank lines no longer end // +build blocks.
+	ended, inComment := false, false
+	sc := bufio.NewScanner(bytes.NewReader(src))
+Lines:
+	for sc.Scan() {
+		offset += len(sc.Bytes()) + 1
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			if !ended {
+				end = offset
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			ended = true
+		}
+		if !inComment {
+			switch {
+			case constraint.IsGoBuild(line):
+				if expr, err := constraint.Parse(line); err == nil {
+					goBuild = append(goBuild, expr)
+				}
+			case constraint.IsPlusBuild(line):
+				if expr, err := constraint.Parse(line); err == nil {
+					plusBuild = append(plusBuild, expr)
+					plusEnds = append(plusEnds, offset)
+				}
+			}
+		}
+		// Skip the comments of the line; any other text ends the header.
+		for line != "" {
+			if inComment {
+				i := strings.Index(line, "*/")
+				if i < 0 {
+					continue Lines
+				}
+				inComment, line = false, strings.TrimSpace(line[i+2:])
+				continue
+			}
+			if strings.HasPrefix(line, "//") {
+				continue Lines
+			}
+			if !strings.HasPrefix(line, "/*") {
+				break Lines
+			}
+			inComment, line = true, strings.TrimSpace(line[2:])
+		}
+	}
+	if len(goBuild) > 0 {
+		return goBuild
+	}
+	var out []constraint.Expr
+	for i, expr := range plusBuild {
+		if plusEnds[i] <= end {
+			out = append(out, expr)
+		}
+	}
+	return out
+}
@@ -0,0 +1,242 @@
+package main
+
+import "strings"
+
+// protoAnalyzer is the Analyzer for protobuf schemas, built on scanProto.
+type protoAnalyzer struct{}
+
+func init() {
+	registerAnalyzer(protoAnalyzer{}, "protobuf")
+}
+
+// Summarize returns the package, imports, messages with their fields, enums
+// with their values and services with their rpc signatures. Comments
+// preceding a declaration are captured if cfg.Docs is set.
+func (protoAnalyzer) Summarize(src []byte, relPath string, cfg *Config) *ASTInfo {
+	return scanProto(src, cfg.Docs).AST
+}
+
+// Symbols lists messages and enums, keyed "Outer.Inner" when nested,
+// services and their rpcs, keyed "Service.Rpc".
+func (protoAnalyzer) Symbols(src []byte, relPath string, cfg *Config) []Symbol {
+	return defSymbols(scanProto(src, false).Defs)
+}
+
+// References lists the message and enum types used by the fields of each
+// message and by the requests and responses of each rpc.
+func (protoAnalyzer) References(src []byte, relPath string, cfg *Config) []Reference {
+	return defReferences(scanProto(src, false).Defs)
+}
+
+// Skeleton reports errNoSkeleton: a schema has no bodies to elide.
+func (protoAnalyzer) Skeleton(src []byte) ([]sourceLine, error) {
+	return nil, errNoSkeleton
+}
+
+// protoServiceSuffixes are appended to a service name by the code
+// generators of the common gRPC plugins: Go, C++ and grpc-web clients and
+// servers, Python servicers and stubs, Java base classes and stubs. The
+// empty suffix covers traits and classes named after the service itself,
+// as generated for Rust.
+var protoServiceSuffixes = []string{"", "Client", "Server", "Servicer", "Stub", "ImplBase", "BlockingStub", "PromiseClient"}
+
+// Generated returns the names of the code protoc and the gRPC plugins
+// generate for sym. Nested messages are also named "Outer_Inner", as in Go.
+func (protoAnalyzer) Generated(sym Symbol) []string {
+	switch sym.Kind {
+	case "service":
+		names := make([]string, 0, len(protoServiceSuffixes))
+		for _, suffix := range protoServiceSuffixes {
+			names = append(names, sym.Key+suffix)
+		}
+		return names
+	case "method":
+		service, rpc, _ := strings.Cut(sym.Key, ".")
+		names := []string{"Unimplemented" + service + "Server." + rpc}
+		for _, suffix := range protoServiceSuffixes {
+			names = append(names, service+suffix+"."+rpc)
+		}
+		return names
+	}
+	if strings.Contains(sym.Key, ".") {
+		return []string{sym.Key, strings.ReplaceAll(sym.Key, ".", "_")}
+	}
+	return []string{sym.Key}
+}
+
+// protoScalars are the scalar field types, which reference no declaration.
+var protoScalars = map[string]bool{
+	"double": true, "float": true, "int32": true, "int64": true,
+	"uint32": true, "uint64": true, "sint32": true, "sint64": true,
+	"fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+	"bool": true, "string": true, "bytes": true,
+}
+
+// protoScope describes the message or service whose body is being scanned.
+type protoScope struct {
+	name    string // qualified name of the message or service
+	class   int    // index into Classes, or -1
+	service int    // index into Interfaces, or -1
+	def     int    // index into Defs, or -1
+}
+
+type protoParser struct {
+	*outlineParser
+}
+
+// scanProto scans the declarations of a protobuf file, descending into
+// nested messages and oneofs.
+func scanProto(src []byte, withDocs bool) *outline {
+	op, _ := newOutlineParser(src, "protobuf", withDocs)
+	p := &protoParser{op}
+	p.decls(0, protoScope{class: -1, service: -1, def: -1})
+	return p.out
+}
+
+// decls scans the declarations from i up to the '}' closing their block
+// and returns its index.
+func (p *protoParser) decls(i int, sc protoScope) int {
+	for i < len(p.toks) && !p.is(i, "}") {
+		if p.is(i, ";") {
+			i++
+			continue
+		}
+		i = p.decl(i, p.stmtEnd(i, false), sc)
+	}
+	return i
+}
+
+// typeName returns the possibly qualified type name starting at i, such as
+// ".google.protobuf.Timestamp", and the index of the token after it.
+func (p *protoParser) typeName(i int) (string, int) {
+	var b strings.Builder
+	for {
+		if p.is(i, ".") {
+			b.WriteByte('.')
+			i++
+		}
+		name := p.ident(i)
+		if name == "" {
+			return b.String(), i
+		}
+		b.WriteString(name)
+		i++
+		if !p.is(i, ".") {
+			return b.String(), i
+		}
+	}
+}
+
+// addRef records a use of the type name by the declaration def. Names are
+// reduced to how they are written within the file's own package.
+func (p *protoParser) addRef(def int, name string) {
+	name = strings.TrimPrefix(name, ".")
+	if pkg := p.out.AST.Package; pkg != "" {
+		name = strings.TrimPrefix(name, pkg+".")
+	}
+	if def >= 0 && name != "" && !protoScalars[name] {
+		p.out.Defs[def].Calls = append(p.out.Defs[def].Calls, name)
+	}
+}
+
+// decl scans the declaration from start to end, its terminating ';' or
+// '{', and returns the index of the token after it.
+func (p *protoParser) decl(start, end int, sc protoScope) int {
+	if end >= len(p.toks) || p.is(end, "}") {
+		return end
+	}
+	next := end + 1
+	if p.is(end, "{") {
+		next = p.match(end) + 1
+	}
+	ai := p.out.AST
+	doc := p.doc(start)
+	qualified := p.ident(start + 1)
+	if sc.name != "" {
+		qualified = sc.name + "." + qualified
+	}
+	switch kw := p.ident(start); {
+	case kw == "syntax", kw == "edition", kw == "option", kw == "reserved", kw == "extensions", kw == "extend":
+	case kw == "package" && sc.name == "":
+		ai.Package = p.text(start+1, end-1)
+		ai.Doc = doc
+	case kw == "import":
+		k := start + 1
+		if p.is(k, "public") || p.is(k, "weak") {
+			k++
+		}
+		ai.Imports = append(ai.Imports, strings.Trim(p.tok(k).Text, `"'`))
+	case kw == "message" && p.is(end, "{"):
+		ai.Classes = append(ai.Classes, ClassInfo{Name: qualified, Kind: "message", Doc: doc, Exported: true})
+		def := p.addDef(qualified, "type", start, next-1)
+		p.decls(end+1, protoScope{name: qualified, class: len(ai.Classes) - 1, service: -1, def: def})
+	case kw == "enum" && p.is(end, "{"):
+		ci := ClassInfo{Name: qualified, Kind: "enum", Doc: doc, Exported: true}
+		for j := end + 1; j < next-1; {
+			if p.is(j+1, "=") && p.ident(j) != "option" {
+				ci.Fields = append(ci.Fields, p.ident(j))
+			}
+			j = p.stmtEnd(j, false) + 1
+		}
+		ai.Classes = append(ai.Classes, ci)
+		p.addDef(qualified, "type", start, next-1)
+	case kw == "service" && p.is(end, "{"):
+		ai.Interfaces = append(ai.Interfaces, InterfaceInfo{Name: qualified, Doc: doc, Exported: true})
+		def := p.addDef(qualified, "service", start, next-1)
+		p.decls(end+1, protoScope{name: qualified, class: -1, service: len(ai.Interfaces) - 1, def: def})
+	case kw == "rpc" && sc.service >= 0:
+		p.rpc(start, end, next, sc, doc)
+	case kw == "oneof" && p.is(end, "{"):
+		// The fields of a oneof belong to its message.
+		p.decls(end+1, sc)
+	case sc.class >= 0:
+		p.field(start, end, sc)
+	}
+	return next
+}
+
+// rpc records the rpc declared from start to end, with its request and
+// response types as references.
+func (p *protoParser) rpc(start, end, next int, sc protoScope, doc string) {
+	ai := p.out.AST
+	name := p.ident(start + 1)
+	last := end - 1
+	def := p.addDef(sc.name+"."+name, "method", start, next-1)
+	for k := start + 2; k < end; k++ {
+		if !p.is(k, "(") {
+			continue
+		}
+		close := p.match(k)
+		from := k + 1
+		if p.is(from, "stream") {
+			from++
+		}
+		typ, _ := p.typeName(from)
+		p.addRef(def, typ)
+		last, k = close, close
+	}
+	sig := p.text(start, last)
+	it := &ai.Interfaces[sc.service]
+	it.Methods = append(it.Methods, sig)
+	ai.Functions = append(ai.Functions, FuncInfo{Name: name, Receiver: sc.name, Signature: sig, Doc: doc, Exported: true})
+}
+
+// field records the message field declared from start to end and the type
+// it references.
+func (p *protoParser) field(start, end int, sc protoScope) {
+	cls := &p.out.AST.Classes[sc.class]
+	cls.Fields = append(cls.Fields, p.text(start, end-1))
+	k := start
+	switch {
+	case p.is(k, "repeated"), p.is(k, "optional"), p.is(k, "required"):
+		k++
+	case p.is(k, "map") && p.is(k+1, "<"):
+		// The value type follows the key type.
+		for k < end && !p.is(k, ",") {
+			k++
+		}
+		k++
+	}
+	typ, _ := p.typeName(k)
+	p.addRef(sc.def, typ)
+}
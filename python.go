@@ -0,0 +1,549 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// pyLine is a logical line of Python source: physical lines joined across
+// open brackets, backslash continuations and multi-line strings, with
+// comments removed. Code is Text with the contents of string literals
+// blanked, so both have the same length and offsets found in Code (where
+// brackets, colons and quotes are unambiguous) can be used to slice Text.
+type pyLine struct {
+	Start, End int // 1-based physical lines
+	Indent     int
+	Text       string
+	Code       string
+}
+
+// defSpan is a function, method or class found by a script analyzer, with
+// the lines it spans (decorators included) and the names it calls.
+type defSpan struct {
+	Key        string // "func", "Class" or "Class.method"
+	Kind       string // "func", "method" or "class"
+	Start, End int
+	Calls      []string
+}
+
+// pyModule is the result of scanning one Python file.
+type pyModule struct {
+	AST  *ASTInfo
+	Defs []defSpan
+}
+
+var (
+	pyDefRe       = regexp.MustCompile(`^(async\s+)?def\s+([A-Za-z_]\w*)\s*\(`)
+	pyClassRe     = regexp.MustCompile(`^class\s+([A-Za-z_]\w*)`)
+	pyImportRe    = regexp.MustCompile(`^import\s+(.+)$`)
+	pyFromRe      = regexp.MustCompile(`^from\s+(\S+)\s+import\b`)
+	pyAssignRe    = regexp.MustCompile(`^([A-Za-z_]\w*)\s*(?::([^=]+))?=[^=]`)
+	pyCallRe      = regexp.MustCompile(`[A-Za-z_][\w.]*\(`)
+	pyDocstringRe = regexp.MustCompile(`^(?:[rRuU]?(?:"""[^"]*"""|'''[^']*'''|"[^"]*"|'[^']*')\s*)+$`)
+	pySpaceRe     = regexp.MustCompile(`\s+`)
+)
+
+// pyKeywords are keywords that may directly precede an opening parenthesis
+// and must not be taken for calls.
+var pyKeywords = map[string]bool{
+	"and": true, "assert": true, "await": true, "del": true, "elif": true,
+	"except": true, "for": true, "if": true, "in": true, "is": true,
+	"lambda": true, "not": true, "or": true, "return": true, "while": true,
+	"with": true, "yield": true,
+}
+
+// pythonModuleName derives the dotted module name of a Python file from its
+// project-relative path; a package's __init__.py is named after the package.
+func pythonModuleName(relPath string) string {
+	p := strings.TrimSuffix(filepath.ToSlash(relPath), ".py")
+	p = strings.TrimSuffix(p, "/__init__")
+	return strings.ReplaceAll(p, "/", ".")
+}
+
+// pythonAnalyzer is the Analyzer for Python, built on scanPython.
+type pythonAnalyzer struct{}
+
+func init() {
+	registerAnalyzer(pythonAnalyzer{}, "python")
+}
+
+// Summarize returns imports, classes with their bases, decorators and
+// methods, top-level functions and module-level assignments. Docstrings
+// are captured if cfg.Docs is set.
+func (pythonAnalyzer) Summarize(src []byte, relPath string, cfg *Config) *ASTInfo {
+	return scanPython(src, relPath, cfg.Docs).AST
+}
+
+func (pythonAnalyzer) Symbols(src []byte, relPath string, cfg *Config) []Symbol {
+	return defSymbols(scanPython(src, relPath, false).Defs)
+}
+
+func (pythonAnalyzer) References(src []byte, relPath string, cfg *Config) []Reference {
+	return defReferences(scanPython(src, relPath, false).Defs)
+}
+
+func (pythonAnalyzer) Skeleton(src []byte) ([]sourceLine, error) {
+	return nil, errNoSkeleton
+}
+
+// scanPython walks the logical lines of a Python file, tracking class and
+// function blocks by indentation. Nested functions are folded into the
+// enclosing top-level function or method, so their calls are attributed to it.
+func scanPython(src []byte, relPath string, withDocs bool) *pyModule {
+	module := pythonModuleName(relPath)
+	isPackage := filepath.Base(relPath) == "__init__.py"
+	ai := &ASTInfo{Package: module, Imports: []string{}, Structs: []StructInfo{}, Functions: []FuncInfo{}}
+	pm := &pyModule{AST: ai}
+
+	type scope struct {
+		indent int
+		class  string // enclosing top-level class, for self.method() calls
+		def    int    // index into pm.Defs owning calls, or -1
+		span   int    // index into pm.Defs ending with the block, or -1
+	}
+	var stack []scope
+	var decorators []string
+	decStart := 0
+	seenImports := map[string]bool{}
+
+	// setDoc, if set, receives the docstring of the block opened last,
+	// which must be indented deeper than docIndent.
+	setDoc := func(doc string) { ai.Doc = doc }
+	docIndent := -1
+
+	for _, l := range pythonLines(src) {
+		for len(stack) > 0 && l.Indent <= stack[len(stack)-1].indent {
+			stack = stack[:len(stack)-1]
+		}
+		for _, s := range stack {
+			if s.span >= 0 {
+				pm.Defs[s.span].End = l.End
+			}
+		}
+
+		if setDoc != nil {
+			isDoc := l.Indent > docIndent && pyDocstringRe.MatchString(l.Code)
+			if isDoc && withDocs {
+				setDoc(pythonDocstring(l.Text))
+			}
+			setDoc = nil
+			if isDoc {
+				continue
+			}
+		}
+
+		cur := scope{def: -1, span: -1}
+		if len(stack) > 0 {
+			cur = stack[len(stack)-1]
+		}
+		topLevel := len(stack) == 0
+
+		if strings.HasPrefix(l.Code, "@") {
+			decorators = append(decorators, pySpaceRe.ReplaceAllString(strings.TrimSpace(l.Text[1:]), " "))
+			if decStart == 0 {
+				decStart = l.Start
+			}
+			continue
+		}
+		start := l.Start
+		if decStart > 0 {
+			start = decStart
+		}
+		decs := decorators
+		decorators, decStart = nil, 0
+
+		if m := pyDefRe.FindStringSubmatchIndex(l.Code); m != nil {
+			name := l.Code[m[4]:m[5]]
+			colon := pyHeaderEnd(l.Code, m[1]-1)
+			sig := compactSignature(l.Text[:colon])
+			exported := pyExported(name)
+			def := cur.def
+			// Only top-level functions and methods of top-level classes
+			// are summarized; nested functions belong to their parent.
+			if topLevel || (cur.class != "" && cur.def < 0 && len(stack) == 1) {
+				fn := FuncInfo{Name: name, Signature: sig, Decorators: decs, Exported: exported}
+				key, kind := name, "func"
+				if !topLevel {
+					kind = "method"
+					fn.Receiver = cur.class
+					key = cur.class + "." + name
+					cls := &ai.Classes[len(ai.Classes)-1]
+					cls.Methods = append(cls.Methods, strings.TrimPrefix(strings.TrimPrefix(sig, "async "), "def "))
+				}
+				ai.Functions = append(ai.Functions, fn)
+				pm.Defs = append(pm.Defs, defSpan{Key: key, Kind: kind, Start: start, End: l.End})
+				def = len(pm.Defs) - 1
+				fi := len(ai.Functions) - 1
+				setDoc, docIndent = func(doc string) { ai.Functions[fi].Doc = doc }, l.Indent
+			}
+			stack = append(stack, scope{indent: l.Indent, class: cur.class, def: def, span: def})
+			if def >= 0 && colon < len(l.Code) {
+				// A body on the header line, as in "def f(): return g()".
+				pm.Defs[def].Calls = append(pm.Defs[def].Calls, pyCalls(l.Code[colon+1:], cur.class)...)
+			}
+			continue
+		}
+
+		if m := pyClassRe.FindStringSubmatchIndex(l.Code); m != nil {
+			name := l.Code[m[2]:m[3]]
+			if topLevel {
+				colon := pyHeaderEnd(l.Code, m[1])
+				var bases []string
+				if rest := strings.TrimSpace(l.Code[m[1]:colon]); strings.HasPrefix(rest, "(") && strings.HasSuffix(rest, ")") {
+					open := strings.Index(l.Code[m[1]:], "(") + m[1]
+					closing := strings.LastIndex(l.Code[:colon], ")")
+					bases = pySplitTopLevel(l.Text[open+1:closing], l.Code[open+1:closing])
+				}
+				ai.Classes = append(ai.Classes, ClassInfo{Name: name, Bases: bases, Decorators: decs, Exported: pyExported(name)})
+				ci := len(ai.Classes) - 1
+				setDoc, docIndent = func(doc string) { ai.Classes[ci].Doc = doc }, l.Indent
+				// The class is a symbol of its own, spanning its body.
+				pm.Defs = append(pm.Defs, defSpan{Key: name, Kind: "class", Start: start, End: l.End})
+				stack = append(stack, scope{indent: l.Indent, class: name, def: -1, span: len(pm.Defs) - 1})
+			} else {
+				stack = append(stack, scope{indent: l.Indent, class: cur.class, def: cur.def, span: cur.def})
+			}
+			continue
+		}
+
+		if m := pyImportRe.FindStringSubmatchIndex(l.Code); m != nil {
+			for _, spec := range strings.Split(l.Code[m[2]:m[3]], ",") {
+				imp := strings.Fields(spec)
+				if len(imp) > 0 && !seenImports[imp[0]] {
+					seenImports[imp[0]] = true
+					ai.Imports = append(ai.Imports, imp[0])
+				}
+			}
+			continue
+		}
+		if m := pyFromRe.FindStringSubmatch(l.Code); m != nil {
+			imp := resolvePythonImport(module, isPackage, m[1])
+			if !seenImports[imp] {
+				seenImports[imp] = true
+				ai.Imports = append(ai.Imports, imp)
+			}
+			continue
+		}
+
+		if topLevel {
+			if m := pyAssignRe.FindStringSubmatchIndex(l.Code); m != nil {
+				name := l.Code[m[2]:m[3]]
+				v := ValueInfo{Name: name, Exported: pyExported(name)}
+				eq := m[1] - 2
+				if m[4] >= 0 {
+					v.Type = strings.TrimSpace(l.Text[m[4]:m[5]])
+				}
+				if value := compactSignature(l.Text[eq+1:]); len(value) <= 80 {
+					v.Value = value
+				}
+				if strings.ToUpper(name) == name {
+					ai.Consts = append(ai.Consts, v)
+				} else {
+					ai.Vars = append(ai.Vars, v)
+				}
+			}
+			continue
+		}
+
+		if cur.def >= 0 {
+			pm.Defs[cur.def].Calls = append(pm.Defs[cur.def].Calls, pyCalls(l.Code, cur.class)...)
+		}
+	}
+	return pm
+}
+
+// pythonLines splits Python source into logical lines.
+func pythonLines(src []byte) []pyLine {
+	s := string(src)
+	var lines []pyLine
+	var text, code strings.Builder
+	line, start, indent, depth := 1, 1, 0, 0
+	atLineStart := true
+	flush := func() {
+		t := text.String()
+		if trimmed := strings.TrimSpace(t); trimmed != "" {
+			// Trim Code by the same amounts to keep offsets aligned.
+			lead := len(t) - len(strings.TrimLeftFunc(t, unicode.IsSpace))
+			c := code.String()[lead : lead+len(trimmed)]
+			lines = append(lines, pyLine{Start: start, End: line, Indent: indent, Text: trimmed, Code: c})
+		}
+		text.Reset()
+		code.Reset()
+	}
+	write := func(b byte) {
+		text.WriteByte(b)
+		code.WriteByte(b)
+	}
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if atLineStart {
+			switch c {
+			case ' ':
+				indent++
+				continue
+			case '\t':
+				indent = (indent/8 + 1) * 8
+				continue
+			}
+			atLineStart = false
+			start = line
+		}
+		switch c {
+		case '#':
+			for i+1 < len(s) && s[i+1] != '\n' {
+				i++
+			}
+		case '\\':
+			if i+1 < len(s) && s[i+1] == '\n' {
+				// Explicit line continuation.
+				i++
+				line++
+				write(' ')
+				write(' ')
+				continue
+			}
+			write(c)
+		case '\n':
+			if depth > 0 {
+				write(' ')
+				line++
+				continue
+			}
+			flush()
+			line++
+			indent = 0
+			atLineStart = true
+		case '(', '[', '{':
+			depth++
+			write(c)
+		case ')', ']', '}':
+			if depth > 0 {
+				depth--
+			}
+			write(c)
+		case '"', '\'':
+			end := pyStringEnd(s, i)
+			lit := s[i:end]
+			text.WriteString(lit)
+			// Keep the quotes in Code but blank the contents.
+			q := 1
+			if len(lit) >= 6 && (strings.HasPrefix(lit, `"""`) || strings.HasPrefix(lit, `'''`)) {
+				q = 3
+			}
+			code.WriteString(lit[:q])
+			if len(lit) >= 2*q {
+				code.WriteString(strings.Repeat(" ", len(lit)-2*q))
+				code.WriteString(lit[len(lit)-q:])
+			} else {
+				code.WriteString(strings.Repeat(" ", len(lit)-q))
+			}
+			line += strings.Count(lit, "\n")
+			i = end - 1
+		default:
+			write(c)
+		}
+	}
+	flush()
+	return lines
+}
+
+// pyStringEnd returns the offset just past the string literal starting at
+// the quote s[i]. Unterminated single-quoted strings end at the newline.
+func pyStringEnd(s string, i int) int {
+	q := s[i]
+	triple := strings.HasPrefix(s[i:], strings.Repeat(string(q), 3))
+	j := i + 1
+	if triple {
+		j = i + 3
+	}
+	for j < len(s) {
+		switch {
+		case s[j] == '\\':
+			j += 2
+			continue
+		case triple && strings.HasPrefix(s[j:], strings.Repeat(string(q), 3)):
+			return j + 3
+		case !triple && s[j] == q:
+			return j + 1
+		case !triple && s[j] == '\n':
+			return j
+		}
+		j++
+	}
+	return len(s)
+}
+
+// pyHeaderEnd returns the offset of the colon ending a def or class header
+// in code, starting the search at from, or len(code) if there is none.
+func pyHeaderEnd(code string, from int) int {
+	depth := 0
+	for i := from; i < len(code); i++ {
+		switch code[i] {
+		case '(', '[', '{':
+			depth++
+		case ')', ']', '}':
+			depth--
+		case ':':
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(code)
+}
+
+// pySplitTopLevel splits text at the commas that code shows are outside brackets.
+func pySplitTopLevel(text, code string) []string {
+	var parts []string
+	depth, last := 0, 0
+	for i := 0; i <= len(code); i++ {
+		if i < len(code) {
+			switch code[i] {
+			case '(', '[', '{':
+				depth++
+				continue
+			case ')', ']', '}':
+				depth--
+				continue
+			case ',':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if part := strings.TrimSpace(text[last:i]); part != "" {
+			parts = append(parts, pySpaceRe.ReplaceAllString(part, " "))
+		}
+		last = i + 1
+	}
+	return parts
+}
+
+// compactSignature collapses the whitespace of a (possibly multi-line) header.
+func compactSignature(text string) string {
+	s := pySpaceRe.ReplaceAllString(strings.TrimSpace(text), " ")
+	s = strings.ReplaceAll(s, "( ", "(")
+	s = strings.ReplaceAll(s, " )", ")")
+	return strings.ReplaceAll(s, ",)", ")") // trailing comma of exploded parameter lists
+}
+
+// pyCalls returns the callee names of the calls in a line of code.
+// self.m() and cls.m() inside a class resolve to "Class.m"; other dotted
+// calls are reduced to their last element. Capitalized names may be class
+// instantiations, which also reach the class's __init__.
+func pyCalls(code, class string) []string {
+	var calls []string
+	for _, m := range pyCallRe.FindAllStringIndex(code, -1) {
+		if m[0] > 0 && (code[m[0]-1] == '.' || isIdentByte(code[m[0]-1])) {
+			continue
+		}
+		parts := strings.Split(code[m[0]:m[1]-1], ".")
+		name := parts[len(parts)-1]
+		switch {
+		case name == "" || pyKeywords[parts[0]]:
+			continue
+		case len(parts) == 2 && class != "" && (parts[0] == "self" || parts[0] == "cls"):
+			calls = append(calls, class+"."+name)
+		case len(parts) == 1 && unicode.IsUpper(rune(name[0])):
+			calls = append(calls, name, name+".__init__")
+		default:
+			calls = append(calls, name)
+		}
+	}
+	return calls
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+// pyExported reports whether a Python name is public: it does not start
+// with an underscore, or it is a dunder name such as __init__.
+func pyExported(name string) bool {
+	return !strings.HasPrefix(name, "_") || (strings.HasPrefix(name, "__") && strings.HasSuffix(name, "__"))
+}
+
+// resolvePythonImport turns a relative "from" import such as "..util" into
+// an absolute module name, relative to module (a package if isPackage).
+// Specs that climb above the project root are returned unchanged.
+func resolvePythonImport(module string, isPackage bool, spec string) string {
+	rest := strings.TrimLeft(spec, ".")
+	dots := len(spec) - len(rest)
+	if dots == 0 {
+		return spec
+	}
+	parts := strings.Split(module, ".")
+	if !isPackage {
+		parts = parts[:len(parts)-1]
+	}
+	if dots-1 > len(parts) {
+		return spec
+	}
+	parts = parts[:len(parts)-(dots-1)]
+	if rest != "" {
+		parts = append(parts, rest)
+	}
+	if len(parts) == 0 || (len(parts) == 1 && parts[0] == "") {
+		return spec
+	}
+	return strings.Join(parts, ".")
+}
+
+// pythonDocstring returns the value of a docstring literal, with its
+// indentation cleaned up like inspect.cleandoc.
+func pythonDocstring(lit string) string {
+	lit = strings.TrimLeft(lit, "rRuU")
+	for _, q := range []string{`"""`, `'''`, `"`, `'`} {
+		if strings.HasPrefix(lit, q) && strings.HasSuffix(lit, q) && len(lit) >= 2*len(q) {
+			lit = lit[len(q) : len(lit)-len(q)]
+			break
+		}
+	}
+	lines := strings.Split(strings.ReplaceAll(lit, "\t", "        "), "\n")
+	margin := -1
+	for _, ln := range lines[1:] {
+		if strings.TrimSpace(ln) == "" {
+			continue
+		}
+		if n := len(ln) - len(strings.TrimLeft(ln, " ")); margin < 0 || n < margin {
+			margin = n
+		}
+	}
+	lines[0] = strings.TrimSpace(lines[0])
+	for i := 1; i < len(lines); i++ {
+		if margin > 0 && len(lines[i]) >= margin {
+			lines[i] = lines[i][margin:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// pythonDocstringLines returns the physical lines of the docstrings of a
+// Python source file: the string literals forming the first statement of the
+// module or of a class or function body. A docstring that is the only
+// statement of its body is left out, as removing it would leave the body
+// empty.
+func pythonDocstringLines(src []byte) map[int]bool {
+	lines := pythonLines(src)
+	doc := map[int]bool{}
+	expect, docIndent := true, -1
+	for i, l := range lines {
+		if expect && l.Indent > docIndent && pyDocstringRe.MatchString(l.Code) &&
+			(docIndent < 0 || i+1 < len(lines) && lines[i+1].Indent > docIndent) {
+			for n := l.Start; n <= l.End; n++ {
+				doc[n] = true
+			}
+		}
+		expect = false
+		if (pyDefRe.MatchString(l.Code) || pyClassRe.MatchString(l.Code)) && strings.HasSuffix(l.Code, ":") {
+			expect, docIndent = true, l.Indent
+		}
+	}
+	return doc
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// rustAnalyzer is the Analyzer for Rust, built on scanRust.
+type rustAnalyzer struct{}
+
+func init() {
+	registerAnalyzer(rustAnalyzer{}, "rust")
+}
+
+// Summarize returns the module path, use declarations, modules, structs with
+// their fields, enums, traits, impl blocks, type aliases, consts, statics
+// and function signatures. Doc comments are captured if cfg.Docs is set.
+func (rustAnalyzer) Summarize(src []byte, relPath string, cfg *Config) *ASTInfo {
+	return scanRust(src, relPath, cfg.Docs).AST
+}
+
+func (rustAnalyzer) Symbols(src []byte, relPath string, cfg *Config) []Symbol {
+	return defSymbols(scanRust(src, relPath, false).Defs)
+}
+
+func (rustAnalyzer) References(src []byte, relPath string, cfg *Config) []Reference {
+	return defReferences(scanRust(src, relPath, false).Defs)
+}
+
+func (rustAnalyzer) Skeleton(src []byte) ([]sourceLine, error) {
+	return scanRust(src, "", false).skeleton(src)
+}
+
+// rustModuleName derives the module path of a Rust file from its
+// project-relative path: src/lib.rs and src/main.rs are the crate root and
+// a mod.rs file is named after its directory.
+func rustModuleName(relPath string) string {
+	p := strings.TrimSuffix(filepath.ToSlash(relPath), ".rs")
+	if i := strings.LastIndex(p, "src/"); i >= 0 && (i == 0 || p[i-1] == '/') {
+		p = p[i+len("src/"):]
+	}
+	p = strings.TrimSuffix(p, "/mod")
+	if p == "lib" || p == "main" || p == "mod" {
+		return "crate"
+	}
+	return "crate::" + strings.ReplaceAll(p, "/", "::")
+}
+
+// rustTypeName returns the bare name of a type as written in an impl
+// header: "&'a mut foo::Bar<T>" is "Bar".
+func rustTypeName(t string) string {
+	t = strings.TrimLeft(t, "&")
+	if strings.HasPrefix(t, "'") {
+		if i := strings.IndexByte(t, ' '); i >= 0 {
+			t = t[i+1:]
+		}
+	}
+	t = strings.TrimPrefix(strings.TrimPrefix(t, "mut "), "dyn ")
+	if i := strings.IndexByte(t, '<'); i >= 0 {
+		t = t[:i]
+	}
+	if i := strings.LastIndex(t, "::"); i >= 0 {
+		t = t[i+2:]
+	}
+	return strings.TrimSpace(t)
+}
+
+// rustScope describes the block whose items are being scanned.
+type rustScope struct {
+	recv  string // type the functions of an impl or trait belong to
+	impl  int    // index into Impls, or -1
+	trait int    // index into Interfaces, or -1
+	pub   bool   // items are public without "pub", as in traits
+}
+
+type rustParser struct {
+	*outlineParser
+}
+
+// scanRust scans the items of a Rust file, descending into inline modules,
+// impl blocks, traits and extern blocks. Function bodies are not parsed
+// beyond the calls they make, so nested items belong to their function.
+func scanRust(src []byte, relPath string, withDocs bool) *outline {
+	op, fileDoc := newOutlineParser(src, "rust", withDocs)
+	p := &rustParser{op}
+	p.out.AST.Package = rustModuleName(relPath)
+	if withDocs {
+		p.out.AST.Doc = cDocText(fileDoc)
+	}
+	p.items(0, rustScope{impl: -1, trait: -1})
+	return p.out
+}
+
+// items scans the items from i up to the '}' closing their block and
+// returns its index.
+func (p *rustParser) items(i int, sc rustScope) int {
+	for i < len(p.toks) && !p.is(i, "}") {
+		if p.is(i, ";") {
+			i++
+			continue
+		}
+		i = p.item(i, p.stmtEnd(i, false), sc)
+	}
+	return i
+}
+
+// attrs skips the outer attributes at i and returns them with the index
+// of the first token after them. Inner attributes are dropped.
+func (p *rustParser) attrs(i int) ([]string, int) {
+	var attrs []string
+	for p.is(i, "#") {
+		if p.is(i+1, "!") {
+			i = p.match(i+2) + 1
+			continue
+		}
+		end := p.match(i + 1)
+		attrs = append(attrs, p.text(i, end))
+		i = end + 1
+	}
+	return attrs, i
+}
+
+// visibility skips a visibility qualifier at i.
+func (p *rustParser) visibility(i int) (bool, int) {
+	if !p.is(i, "pub") {
+		return false, i
+	}
+	if p.is(i+1, "(") {
+		return true, p.match(i+1) + 1
+	}
+	return true, i + 1
+}
+
+// item scans the item from start to end, its terminating ';' or '{', and
+// returns the index of the token after it.
+func (p *rustParser) item(start, end int, sc rustScope) int {
+	if end >= len(p.toks) || p.is(end, "}") {
+		return end
+	}
+	next := end + 1
+	if p.is(end, "{") {
+		next = p.match(end) + 1
+	}
+	attrs, j := p.attrs(start)
+	sigFrom := j
+	pub, j := p.visibility(j)
+	pub = pub || sc.pub
+modifiers:
+	for {
+		switch {
+		case p.is(j, "async"), p.is(j, "unsafe"), p.is(j, "default"):
+			j++
+		case p.is(j, "const") && (p.is(j+1, "fn") || p.is(j+1, "unsafe") || p.is(j+1, "async")):
+			j++
+		case p.is(j, "extern") && p.tok(j+1).Kind == 's':
+			j += 2
+		case p.is(j, "extern") && p.is(j+1, "fn"):
+			j++
+		default:
+			break modifiers
+		}
+	}
+
+	ai := p.out.AST
+	doc := p.doc(start)
+	kw := p.ident(j)
+	switch {
+	case j == end && p.is(end, "{"):
+		// extern "C" { ... }
+		return p.items(end+1, sc) + 1
+	case sc.impl >= 0 && kw != "fn":
+		// Associated consts and types of impl blocks are not summarized.
+		return next
+	case sc.trait >= 0 && kw != "fn":
+		it := &ai.Interfaces[sc.trait]
+		it.Methods = append(it.Methods, p.text(j, end-1))
+		return next
+	}
+	switch kw {
+	case "use":
+		ai.Imports = append(ai.Imports, p.text(j+1, end-1))
+	case "extern":
+		if p.is(j+1, "crate") {
+			ai.Imports = append(ai.Imports, p.ident(j+2))
+		}
+	case "mod":
+		ai.Modules = append(ai.Modules, p.ident(j+1))
+		if p.is(end, "{") {
+			return p.items(end+1, rustScope{impl: -1, trait: -1}) + 1
+		}
+	case "fn":
+		return p.fn(start, sigFrom, j, end, sc, pub, attrs)
+	case "struct", "union":
+		p.structItem(j, end, pub, doc)
+		p.addDef(p.ident(j+1), "type", start, next-1)
+	case "enum":
+		name := p.ident(j + 1)
+		t := TypeInfo{Name: name, Type: "enum", Doc: doc, Exported: pub}
+		if p.is(j+2, "<") {
+			t.TypeParams = p.text(j+2, p.angleEnd(j+2))
+		}
+		ai.Types = append(ai.Types, t)
+		p.addDef(name, "type", start, next-1)
+	case "type":
+		name := p.ident(j + 1)
+		t := TypeInfo{Name: name, Alias: true, Doc: doc, Exported: pub}
+		k := j + 2
+		if p.is(k, "<") {
+			e := p.angleEnd(k)
+			t.TypeParams = p.text(k, e)
+			k = e + 1
+		}
+		if p.is(k, "=") {
+			t.Type = p.text(k+1, end-1)
+		}
+		ai.Types = append(ai.Types, t)
+		p.addDef(name, "type", start, next-1)
+	case "trait":
+		name := p.ident(j + 1)
+		it := InterfaceInfo{Name: name, Doc: doc, Exported: pub}
+		k := j + 2
+		if p.is(k, "<") {
+			e := p.angleEnd(k)
+			it.TypeParams = p.text(k, e)
+			k = e + 1
+		}
+		if p.is(k, ":") {
+			stop := k + 1
+			for stop < end && !p.is(stop, "where") {
+				stop++
+			}
+			for _, b := range p.splitTopLevel(k+1, stop-1, "+") {
+				it.Embeds = append(it.Embeds, p.text(b[0], b[1]))
+			}
+		}
+		ai.Interfaces = append(ai.Interfaces, it)
+		p.addDef(name, "type", start, next-1)
+		if p.is(end, "{") {
+			p.items(end+1, rustScope{recv: name, impl: -1, trait: len(ai.Interfaces) - 1, pub: pub})
+		}
+	case "impl":
+		im := ImplInfo{}
+		k := j + 1
+		if p.is(k, "<") {
+			e := p.angleEnd(k)
+			im.TypeParams = p.text(k, e)
+			k = e + 1
+		}
+		stop, forAt := end, -1
+		for m := k; m < end; m++ {
+			switch {
+			case p.is(m, "<"):
+				m = p.angleEnd(m)
+			case p.is(m, "for") && forAt < 0:
+				forAt = m
+			case p.is(m, "where"):
+				stop = m
+			}
+			if stop < end {
+				break
+			}
+		}
+		if forAt >= 0 {
+			im.Trait = p.text(k, forAt-1)
+			im.Type = p.text(forAt+1, stop-1)
+		} else {
+			im.Type = p.text(k, stop-1)
+		}
+		ai.Impls = append(ai.Impls, im)
+		if p.is(end, "{") {
+			p.items(end+1, rustScope{recv: rustTypeName(im.Type), impl: len(ai.Impls) - 1, trait: -1})
+		}
+	case "const", "static":
+		k := j + 1
+		if p.is(k, "mut") {
+			k++
+		}
+		v := ValueInfo{Name: p.ident(k), Exported: pub}
+		eq := k
+		for eq < end && !p.is(eq, "=") {
+			eq++
+		}
+		if p.is(k+1, ":") {
+			v.Type = p.text(k+2, eq-1)
+		}
+		if value := p.text(eq+1, end-1); eq < end && len(value) <= 80 {
+			v.Value = value
+		}
+		if kw == "const" {
+			ai.Consts = append(ai.Consts, v)
+		} else {
+			ai.Vars = append(ai.Vars, v)
+		}
+	}
+	return next
+}
+
+// fn records the function whose keyword is at j and returns the index of
+// the token after it.
+func (p *rustParser) fn(start, sigFrom, j, end int, sc rustScope, pub bool, attrs []string) int {
+	ai := p.out.AST
+	name := p.ident(j + 1)
+	sig := strings.TrimSuffix(p.text(sigFrom, end-1), ",") // trailing comma of a where clause
+	key, kind := name, "func"
+	if sc.recv != "" {
+		key, kind = sc.recv+"."+name, "method"
+	}
+	switch {
+	case sc.impl >= 0:
+		ai.Impls[sc.impl].Methods = append(ai.Impls[sc.impl].Methods, sig)
+	case sc.trait >= 0:
+		ai.Interfaces[sc.trait].Methods = append(ai.Interfaces[sc.trait].Methods, sig)
+	}
+	ai.Functions = append(ai.Functions, FuncInfo{Name: name, Receiver: sc.recv, Signature: sig, Decorators: attrs, Doc: p.doc(start), Exported: pub})
+	def := p.addDef(key, kind, start, end)
+	if !p.is(end, "{") {
+		return end + 1
+	}
+	close := p.match(end)
+	p.addBody(def, end, close, sc.recv)
+	return close + 1
+}
+
+// structItem records the struct or union whose keyword is at j, with the
+// fields of its braces or tuple parentheses.
+func (p *rustParser) structItem(j, end int, pub bool, doc string) {
+	st := StructInfo{Name: p.ident(j + 1), Doc: doc, Exported: pub}
+	k := j + 2
+	if p.is(k, "<") {
+		e := p.angleEnd(k)
+		st.TypeParams = p.text(k, e)
+		k = e + 1
+	}
+	switch {
+	case p.is(k, "("):
+		for n, f := range p.splitTopLevel(k+1, p.match(k)-1, ",") {
+			_, from := p.attrs(f[0])
+			fpub, from := p.visibility(from)
+			st.Fields = append(st.Fields, FieldInfo{Name: strconv.Itoa(n), Type: p.text(from, f[1]), Exported: fpub})
+		}
+	case p.is(end, "{"):
+		for _, f := range p.splitTopLevel(end+1, p.match(end)-1, ",") {
+			_, from := p.attrs(f[0])
+			fpub, from := p.visibility(from)
+			if p.is(from+1, ":") {
+				st.Fields = append(st.Fields, FieldInfo{Name: p.ident(from), Type: p.text(from+2, f[1]), Exported: fpub})
+			}
+		}
+	}
+	p.out.AST.Structs = append(p.out.AST.Structs, st)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// skeletonBody replaces every elided function body.
+const skeletonBody = "{ ... }"
+
+// useSkeleton reports whether the file at relPath should be reduced to its
+// skeleton, either because skeleton mode is global or a skeleton glob matches.
+// Only languages with an analyzer can be reduced.
+func useSkeleton(relPath, language string, cfg *Config) bool {
+	if analyzerFor(language) == nil {
+		return false
+	}
+	return cfg.Skeleton || matchesAnyGlob(relPath, cfg.SkeletonGlobs)
+}
+
+// matchesAnyGlob reports whether any pattern matches path or its basename.
+func matchesAnyGlob(path string, patterns []string) bool {
+	for _, pat := range patterns {
+		if ok, _ := doublestar.Match(pat, path); ok {
+			return true
+		}
+		if ok, _ := doublestar.Match(pat, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// goSkeleton returns a Go source file with every function body, including
+// function literals in package-level declarations, replaced by "{ ... }".
+// Package clause, imports, type declarations, consts, vars, signatures and
+// doc comments are kept. Bodies are located with go/ast and cut out of the
+// original text rather than re-printed, so formatting and the original line
+// numbers of the remaining lines are preserved.
+func goSkeleton(src []byte) ([]sourceLine, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect bodies in source order; nested literals are removed with their parent.
+	var bodies []*ast.BlockStmt
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Body != nil {
+				bodies = append(bodies, d.Body)
+			}
+		case *ast.GenDecl:
+			ast.Inspect(d, func(n ast.Node) bool {
+				if fl, ok := n.(*ast.FuncLit); ok {
+					bodies = append(bodies, fl.Body)
+					return false
+				}
+				return true
+			})
+		}
+	}
+
+	spans := make([][2]int, len(bodies))
+	for i, body := range bodies {
+		spans[i] = [2]int{fset.Position(body.Lbrace).Offset, fset.Position(body.Rbrace).Offset + 1}
+	}
+	return elideBodies(string(src), spans), nil
+}
+
+// elideBodies replaces each body of text, given as sorted, non-overlapping
+// [start, end) byte offsets from its opening to past its closing brace, with
+// "{ ... }". The rest of the closing brace's line joins the line the body
+// started on; all other lines keep their original numbers.
+func elideBodies(text string, bodies [][2]int) []sourceLine {
+	var lines []sourceLine
+	var cur strings.Builder
+	line, curNum := 1, 1
+	copyText := func(s string) {
+		for i := 0; i < len(s); i++ {
+			if s[i] != '\n' {
+				cur.WriteByte(s[i])
+				continue
+			}
+			lines = append(lines, sourceLine{Num: curNum, Text: cur.String()})
+			cur.Reset()
+			line++
+			curNum = line
+		}
+	}
+
+	prev := 0
+	for _, body := range bodies {
+		copyText(text[prev:body[0]])
+		cur.WriteString(skeletonBody)
+		line += strings.Count(text[body[0]:body[1]], "\n")
+		prev = body[1]
+	}
+	copyText(text[prev:])
+	if cur.Len() > 0 {
+		lines = append(lines, sourceLine{Num: curNum, Text: cur.String()})
+	}
+	return lines
+}
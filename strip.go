@@ -0,0 +1,853 @@
+package main
+
+import (
+	"go/scanner"
+	"go/token"
+	"regexp"
+	"strings"
+)
+
+// stripComments removes the comments of a file that policy does not keep,
+// found by a lexer for its language family so that comment markers inside
+// string literals, such as the "//" of a URL, are left alone. Lines left
+// empty by a removed comment are dropped; blank lines of the original are
+// kept for compactWhitespace. Line numbers of the remaining lines are
+// preserved, and lines whose number is in keep are left untouched.
+func stripComments(lines []sourceLine, language, policy string, keep map[int]bool) []sourceLine {
+	if len(lines) == 0 {
+		return lines
+	}
+	texts := make([]string, len(lines))
+	for i, ln := range lines {
+		if !keep[ln.Num] {
+			texts[i] = ln.Text
+		}
+	}
+	content := strings.Join(texts, "\n")
+	content = removeSpans(content, strippedSpans(content, language, policy))
+
+	stripped := strings.Split(content, "\n")
+	out := make([]sourceLine, 0, len(lines))
+	for i, ln := range stripped {
+		if keep[lines[i].Num] {
+			out = append(out, lines[i])
+			continue
+		}
+		ln = strings.TrimRight(ln, " \t")
+		if strings.TrimSpace(ln) != "" || strings.TrimSpace(lines[i].Text) == "" {
+			out = append(out, sourceLine{Num: lines[i].Num, Text: ln})
+		}
+	}
+	return out
+}
+
+// collapseLanguages are the languages whose blank lines carry structure, so
+// that stripping only collapses runs of them by default.
+var collapseLanguages = map[string]bool{
+	"python": true, "markdown": true, "yaml": true,
+}
+
+// whitespaceMode returns how the blank lines of a file in language are
+// compacted: the mode configured for the language, else for "*", else
+// "remove" when stripping comments, except for collapseLanguages, and
+// "keep" otherwise.
+func whitespaceMode(language string, cfg *Config) string {
+	if mode := cfg.Whitespace[language]; mode != "" {
+		return mode
+	}
+	if mode := cfg.Whitespace["*"]; mode != "" {
+		return mode
+	}
+	switch {
+	case !cfg.StripComments:
+		return "keep"
+	case collapseLanguages[language]:
+		return "collapse"
+	}
+	return "remove"
+}
+
+// compactWhitespace trims trailing whitespace and then, depending on mode,
+// removes every blank line ("remove"), reduces runs of blank lines to one
+// ("collapse") or leaves them ("keep"). Unless kept, blank lines at the
+// start, as left when the comments or notebook cells above them were
+// stripped, are removed. Lines whose number is in keep are left untouched.
+func compactWhitespace(lines []sourceLine, mode string, keep map[int]bool) []sourceLine {
+	if mode == "keep" {
+		return lines
+	}
+	out := lines[:0]
+	blank := true
+	for _, ln := range lines {
+		if keep[ln.Num] {
+			out, blank = append(out, ln), false
+			continue
+		}
+		ln.Text = strings.TrimRight(ln.Text, " \t")
+		if strings.TrimSpace(ln.Text) == "" {
+			if mode == "remove" || blank {
+				continue
+			}
+			blank = true
+		} else {
+			blank = false
+		}
+		out = append(out, ln)
+	}
+	return out
+}
+
+// removeSpans removes the [start, end) byte spans from src, which must be
+// sorted and disjoint, except for their newlines, so that line positions
+// after a multi-line comment are unchanged. The spaces that separated a
+// span from the code around it go with it.
+func removeSpans(src string, spans [][2]int) string {
+	if len(spans) == 0 {
+		return src
+	}
+	var b strings.Builder
+	b.Grow(len(src))
+	prev := 0
+	blank := func(c byte) bool { return c == ' ' || c == '\t' }
+	for _, sp := range spans {
+		start, end := sp[0], sp[1]
+		// Take the spaces separating the comment from the code with it: those
+		// after it when it starts a line or follows a space, else those
+		// before it unless that would join two words.
+		if start == 0 || src[start-1] == '\n' || blank(src[start-1]) {
+			for end < len(src) && blank(src[end]) {
+				end++
+			}
+		}
+		if end == sp[1] && (end == len(src) || !isWordByte(src[end])) {
+			for start > prev && blank(src[start-1]) {
+				start--
+			}
+		}
+		b.WriteString(src[prev:start])
+		b.WriteString(strings.Repeat("\n", strings.Count(src[start:end], "\n")))
+		prev = end
+	}
+	b.WriteString(src[prev:])
+	return b.String()
+}
+
+// strippedSpans returns the spans of the comments of src that policy
+// removes:
+//
+//   - "all" removes every comment.
+//   - "keep-directives" keeps the directives read by compilers, linters and
+//     other tools, such as //go:build, # noqa or // eslint-disable.
+//   - "non-doc" also keeps doc comments, such as /** ... */ and ///.
+//   - "license-only" keeps the directives and the license header.
+func strippedSpans(src, language, policy string) [][2]int {
+	spans := commentSpans(src, language)
+	if policy == "all" {
+		return spans
+	}
+	var from, to int
+	if policy == "license-only" {
+		from, to = licenseHeader(src, spans)
+	}
+	out := spans[:0]
+	for i, sp := range spans {
+		text := src[sp[0]:sp[1]]
+		switch {
+		case isDirective(text), language == "go" && precedesCgoImport(src[sp[1]:]):
+		case policy == "non-doc" && isDocComment(text):
+		case i >= from && i < to:
+		default:
+			out = append(out, sp)
+		}
+	}
+	return out
+}
+
+// directivePrefixes start the comments that tools read as directives.
+var directivePrefixes = []string{
+	"#!", "//go:", "// +build", "//line ", "//export ", "//nolint", "//lint:",
+	"/// <reference", "//# sourceMappingURL", "/* global ", "/*global ",
+	"/* webpack", "/*webpack", "/* @vite-ignore", "/* @__PURE__", "/*#__PURE__",
+}
+
+// directiveWords start the text of the comments that tools read as
+// directives, after the comment marker and any spaces. Words ending in a
+// letter must be followed by a non-word character, so "noqa" does not match
+// "noqa-free".
+var directiveWords = []string{
+	"type:", "noqa", "pylint:", "pragma:", "fmt:", "isort:", "mypy:", "pyright:",
+	"-*-", "vim:", "coding:", "coding=", "encoding:", "frozen_string_literal:", "rubocop:",
+	"eslint-", "eslint ", "@ts-", "prettier-ignore", "istanbul ignore", "c8 ignore",
+	"@flow", "@jsx", "@jsxImportSource", "@jsxRuntime", "@refresh", "biome-ignore",
+	"NOLINT", "NOLINTNEXTLINE", "NOLINTBEGIN", "NOLINTEND", "clang-format ", "shellcheck ",
+	"syntax=", "escape=", "check=", "yaml-language-server:", "Code generated ",
+	"%%", // cell markers of percent-format scripts, as notebooks are read
+}
+
+// isDirective reports whether the comment text is a directive that changes
+// how the code is built, checked or formatted.
+func isDirective(text string) bool {
+	for _, prefix := range directivePrefixes {
+		if strings.HasPrefix(text, prefix) {
+			return true
+		}
+	}
+	body := strings.TrimLeft(text, "/*# \t")
+	for _, word := range directiveWords {
+		if !strings.HasPrefix(body, word) {
+			continue
+		}
+		if rest := body[len(word):]; rest == "" || !isWordByte(word[len(word)-1]) || !isWordByte(rest[0]) && rest[0] != '-' {
+			return true
+		}
+	}
+	return false
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// precedesCgoImport reports whether rest, the source following a Go
+// comment, starts with import "C", making the comment the cgo preamble.
+func precedesCgoImport(rest string) bool {
+	return strings.HasPrefix(strings.TrimLeft(rest, " \t\r\n"), `import "C"`)
+}
+
+// isDocComment reports whether the comment text uses one of the doc comment
+// forms of the C family: /** ... */ and /*! ... */ blocks, /// and //! lines.
+// Go doc comments are ordinary comments, kept by line instead.
+func isDocComment(text string) bool {
+	switch {
+	case strings.HasPrefix(text, "/**"):
+		return text != "/**/" && !strings.HasPrefix(text, "/***")
+	case strings.HasPrefix(text, "///"):
+		return !strings.HasPrefix(text, "////")
+	}
+	return strings.HasPrefix(text, "/*!") || strings.HasPrefix(text, "//!")
+}
+
+// licenseHeader returns the range of the spans forming the license header
+// of src: the first paragraph of the comments preceding any code that
+// mentions a copyright or license. A paragraph is a block comment or a run of
+// line comments ended by a blank line.
+func licenseHeader(src string, spans [][2]int) (from, to int) {
+	prev := 0
+	for i := 0; i < len(spans); {
+		if strings.TrimSpace(src[prev:spans[i][0]]) != "" {
+			return 0, 0
+		}
+		j := i + 1
+		for j < len(spans) {
+			gap := src[spans[j-1][1]:spans[j][0]]
+			if strings.TrimSpace(gap) != "" || strings.Count(gap, "\n") > 1 ||
+				isBlockComment(src[spans[j-1][0]:spans[j-1][1]]) || isBlockComment(src[spans[j][0]:spans[j][1]]) {
+				break
+			}
+			j++
+		}
+		text := strings.ToLower(src[spans[i][0]:spans[j-1][1]])
+		if strings.Contains(text, "copyright") || strings.Contains(text, "license") {
+			return i, j
+		}
+		prev, i = spans[j-1][1], j
+	}
+	return 0, 0
+}
+
+// isBlockComment reports whether the comment text is delimited at both
+// ends, as /* ... */ or <!-- ... --> are, rather than ended by a newline.
+func isBlockComment(text string) bool {
+	return strings.HasSuffix(text, "*/") || strings.HasSuffix(text, "-->") || strings.Contains(text, "\n")
+}
+
+// commentSpans returns the byte spans of the comments of src, in order.
+// Languages without a known comment syntax have none.
+func commentSpans(src, language string) [][2]int {
+	switch language {
+	case "go":
+		return goCommentSpans(src)
+	case "javascript", "typescript", "jsx", "tsx":
+		return jsCommentSpans(src)
+	case "java", "c", "cpp", "csharp", "rust", "swift", "kotlin", "scala",
+		"protobuf", "gomod", "groovy", "terraform", "hcl", "css", "scss", "sass", "less":
+		return cCommentSpans(src, language)
+	case "python", "starlark", "toml", "ruby", "perl", "r", "cmake", "powershell":
+		return hashCommentSpans(src, language)
+	case "shell", "bash", "zsh", "makefile", "dockerfile":
+		return shellCommentSpans(src, language)
+	case "yaml":
+		return yamlCommentSpans(src)
+	case "sql":
+		return sqlCommentSpans(src)
+	case "html", "xml", "vue", "svelte":
+		return markupCommentSpans(src, language)
+	}
+	return nil
+}
+
+// lineEnd returns the offset of the newline ending the line that contains
+// i, or len(s).
+func lineEnd(s string, i int) int {
+	if j := strings.IndexByte(s[i:], '\n'); j >= 0 {
+		return i + j
+	}
+	return len(s)
+}
+
+// delimitedEnd returns the offset just past the first close at or after i,
+// or len(s) if there is none.
+func delimitedEnd(s string, i int, close string) int {
+	if j := strings.Index(s[i:], close); j >= 0 {
+		return i + j + len(close)
+	}
+	return len(s)
+}
+
+// goCommentSpans finds the comments of Go source with go/scanner.
+func goCommentSpans(src string) [][2]int {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	// Without an error handler, errors such as a string cut by a line
+	// range are counted and scanning goes on.
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+	var spans [][2]int
+	for {
+		pos, tok, _ := s.Scan()
+		if tok == token.EOF {
+			return spans
+		}
+		if tok != token.COMMENT {
+			continue
+		}
+		// The literal of a comment has its carriage returns removed, so
+		// its end is found in the source.
+		start := file.Offset(pos)
+		end := lineEnd(src, start)
+		if strings.HasPrefix(src[start:], "/*") {
+			end = delimitedEnd(src, start+2, "*/")
+		}
+		spans = append(spans, [2]int{start, end})
+	}
+}
+
+// jsCommentSpans finds the comments of JavaScript and TypeScript, which
+// fill the gaps between the tokens of jsTokens.
+func jsCommentSpans(s string) [][2]int {
+	var spans [][2]int
+	prev := 0
+	for _, t := range append(jsTokens(s), jsToken{Off: len(s)}) {
+		for i := prev; i < t.Off; {
+			switch {
+			case strings.HasPrefix(s[i:], "//"):
+				j := lineEnd(s, i)
+				spans = append(spans, [2]int{i, j})
+				i = j
+			case strings.HasPrefix(s[i:], "/*"):
+				j := delimitedEnd(s, i+2, "*/")
+				spans = append(spans, [2]int{i, j})
+				i = j
+			default:
+				i++
+			}
+		}
+		prev = max(prev, t.End)
+	}
+	return spans
+}
+
+// cCommentSpans finds the "//" and "/* */" comments of the C family of
+// languages, skipping string and character literals, including raw
+// strings of C++ and Rust, text blocks of Java, Kotlin, Scala, Swift and
+// Groovy, and verbatim strings of C#. Block comments nest in Rust, Swift,
+// Kotlin and Scala. CSS has only block comments; Terraform and HCL also
+// have '#' comments.
+func cCommentSpans(s, lang string) [][2]int {
+	var (
+		slash     = lang != "css"
+		hash      = lang == "terraform" || lang == "hcl"
+		nested    = lang == "rust" || lang == "swift" || lang == "kotlin" || lang == "scala"
+		triple    = lang == "java" || lang == "kotlin" || lang == "scala" || lang == "swift" || lang == "groovy" || lang == "csharp"
+		lifetimes = lang == "rust" || lang == "scala"
+	)
+	var spans [][2]int
+	for i := 0; i < len(s); {
+		c := s[i]
+		next := i + 1
+		switch {
+		case c == '/' && i+1 < len(s) && s[i+1] == '*':
+			j, depth := i+2, 1
+			for j < len(s) && depth > 0 {
+				switch {
+				case strings.HasPrefix(s[j:], "*/"):
+					depth--
+					j += 2
+				case nested && strings.HasPrefix(s[j:], "/*"):
+					depth++
+					j += 2
+				default:
+					j++
+				}
+			}
+			spans = append(spans, [2]int{i, j})
+			next = j
+		case slash && c == '/' && i+1 < len(s) && s[i+1] == '/', hash && c == '#':
+			next = lineEnd(s, i)
+			spans = append(spans, [2]int{i, next})
+		case triple && strings.HasPrefix(s[i:], `"""`):
+			next = delimitedEnd(s, i+3, `"""`)
+		case c == '"', c == '\'' && (!lifetimes || rustCharLiteral(s, i)):
+			next = cStringEnd(s, i, lang)
+		case lang == "csharp" && c == '@' && i+1 < len(s) && s[i+1] == '"':
+			// Verbatim strings escape quotes by doubling them.
+			for next = i + 2; next < len(s); next++ {
+				if s[next] == '"' {
+					if next+1 < len(s) && s[next+1] == '"' {
+						next++
+						continue
+					}
+					next++
+					break
+				}
+			}
+		case isCIdentByte(c):
+			next = i + 1
+			for next < len(s) && isCIdentByte(s[next]) {
+				next++
+				// Digit separators of C and C++ numbers, as in 1'000.
+				if c >= '0' && c <= '9' && next+1 < len(s) && s[next] == '\'' && isCIdentByte(s[next+1]) {
+					next++
+				}
+			}
+			// String prefixes: r"", r#""#, b"" in Rust; u8"", R"()" in C++.
+			if next < len(s) && (s[next] == '"' || lang == "rust" && s[next] == '#' && rustRawQuote(s, next)) && cStringPrefix(s[i:next], lang) {
+				next = cRawStringEnd(s, i, next, lang)
+			}
+		}
+		i = next
+	}
+	return spans
+}
+
+// quotedEnd returns the offset just past the string starting with the
+// quote at i. Backslashes escape if escapes is set; unless multiline is
+// set, an unterminated string ends at the newline.
+func quotedEnd(s string, i int, escapes, multiline bool) int {
+	q := s[i]
+	for j := i + 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\' && escapes:
+			j++
+		case s[j] == q:
+			return j + 1
+		case s[j] == '\n' && !multiline:
+			return j
+		}
+	}
+	return len(s)
+}
+
+// hashCommentSpans finds the '#' comments of Python and the languages that
+// share its comment and string syntax, skipping strings, including triple
+// quoted ones. CMake bracket comments ("#[[ ]]"), PowerShell block
+// comments ("<# #>") and Ruby "=begin" blocks are comments too; a '#' after
+// a '$', as in Perl's "$#array", is not.
+func hashCommentSpans(s, lang string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '#' && lang == "cmake" && strings.HasPrefix(s[i:], "#[["):
+			j := delimitedEnd(s, i+3, "]]")
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case c == '<' && lang == "powershell" && strings.HasPrefix(s[i:], "<#"):
+			j := delimitedEnd(s, i+2, "#>")
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case c == '=' && lang == "ruby" && (i == 0 || s[i-1] == '\n') && strings.HasPrefix(s[i:], "=begin"):
+			j := delimitedEnd(s, i, "\n=end")
+			j = lineEnd(s, j-1)
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case c == '#' && (i == 0 || s[i-1] != '$'):
+			j := lineEnd(s, i)
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case (c == '"' || c == '\'') && (strings.HasPrefix(s[i:], `"""`) || strings.HasPrefix(s[i:], "'''")):
+			i = delimitedEnd(s, i+3, s[i:i+3])
+		case c == '"' || c == '\'':
+			// TOML literal strings have no escapes.
+			i = quotedEnd(s, i, !(lang == "toml" && c == '\''), lang == "ruby" || lang == "perl" || lang == "r" || lang == "powershell")
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+// shellCommentSpans finds the '#' comments of shell scripts, which start a
+// word, skipping quoted strings and here-documents. In a Dockerfile only
+// lines starting with '#' are comments. In a Makefile any unescaped '#'
+// starts one, except in recipe lines, which belong to the shell.
+func shellCommentSpans(s, lang string) [][2]int {
+	var spans [][2]int
+	switch lang {
+	case "dockerfile":
+		for i := 0; i < len(s); i = lineEnd(s, i) + 1 {
+			if t := strings.TrimLeft(s[i:lineEnd(s, i)], " \t"); strings.HasPrefix(t, "#") {
+				spans = append(spans, [2]int{lineEnd(s, i) - len(t), lineEnd(s, i)})
+			}
+		}
+		return spans
+	case "makefile":
+		for i := 0; i < len(s); i = lineEnd(s, i) + 1 {
+			if s[i] == '\t' {
+				continue
+			}
+			for j := i; j < lineEnd(s, i); j++ {
+				if s[j] == '\\' {
+					j++
+				} else if s[j] == '#' {
+					spans = append(spans, [2]int{j, lineEnd(s, i)})
+					break
+				}
+			}
+		}
+		return spans
+	}
+	type heredoc struct {
+		word string
+		tabs bool // "<<-" strips leading tabs
+	}
+	var pending []heredoc
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			i++
+			// Skip the bodies of the here-documents started on the line.
+			for _, h := range pending {
+				for i < len(s) {
+					line := s[i:lineEnd(s, i)]
+					i = lineEnd(s, i) + 1
+					if h.tabs {
+						line = strings.TrimLeft(line, "\t")
+					}
+					if line == h.word {
+						break
+					}
+				}
+			}
+			pending = nil
+		case c == '\\':
+			i += 2
+		case c == '#' && (i == 0 || strings.IndexByte(" \t\n;|&()", s[i-1]) >= 0):
+			j := lineEnd(s, i)
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case c == '\'':
+			i = quotedEnd(s, i, false, true)
+		case c == '"' || c == '`':
+			i = quotedEnd(s, i, true, true)
+		case c == '<' && strings.HasPrefix(s[i:], "<<") && !strings.HasPrefix(s[i:], "<<<"):
+			j := i + 2
+			h := heredoc{}
+			if j < len(s) && s[j] == '-' {
+				h.tabs = true
+				j++
+			}
+			for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+				j++
+			}
+			k := j
+			if k < len(s) && s[k] >= '0' && s[k] <= '9' {
+				// A shift, as in $((1<<2)).
+				i = k
+				continue
+			}
+			for k < len(s) && (isCIdentByte(s[k]) || s[k] == '\'' || s[k] == '"' || s[k] == '\\') {
+				k++
+			}
+			if h.word = strings.NewReplacer(`'`, "", `"`, "", `\`, "").Replace(s[j:k]); h.word != "" {
+				pending = append(pending, h)
+			}
+			i = k
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+// yamlCommentSpans finds the comments of YAML, which start with a '#' at
+// the start of a line or after whitespace, skipping quoted scalars and
+// the contents of block scalars ('|' and '>').
+func yamlCommentSpans(s string) [][2]int {
+	spans, _ := yamlScan(s)
+	return spans
+}
+
+// yamlBlockScalarLines returns the numbers of the lines holding the
+// contents of block scalars, whose whitespace is part of their value.
+func yamlBlockScalarLines(src []byte) map[int]bool {
+	s := string(src)
+	_, blocks := yamlScan(s)
+	lines := map[int]bool{}
+	for _, b := range blocks {
+		first := strings.Count(s[:b[0]], "\n") + 1
+		last := first + strings.Count(s[b[0]:b[1]-1], "\n")
+		for n := first; n <= last; n++ {
+			lines[n] = true
+		}
+	}
+	return lines
+}
+
+// yamlScan lexes YAML, returning the spans of its comments and of the
+// contents of its block scalars.
+func yamlScan(s string) (spans, blocks [][2]int) {
+	lineStart, blockIndent := 0, -1
+	indent := func(i int) int {
+		n := 0
+		for i+n < len(s) && s[i+n] == ' ' {
+			n++
+		}
+		return n
+	}
+	// scalarStart reports whether a scalar may start at i: first on its
+	// line, or after a "key:", "-", "?" or flow collection punctuation.
+	scalarStart := func(i int) bool {
+		k := i - 1
+		for k >= lineStart && (s[k] == ' ' || s[k] == '\t') {
+			k--
+		}
+		if k < lineStart {
+			return true
+		}
+		return strings.IndexByte("[{,", s[k]) >= 0 || strings.IndexByte(":-?", s[k]) >= 0 && k < i-1
+	}
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			i++
+			lineStart = i
+			if blockIndent < 0 {
+				continue
+			}
+			// Block scalar content is more indented than its key, or blank.
+			from := i
+			for i < len(s) {
+				end := lineEnd(s, i)
+				if strings.TrimSpace(s[i:end]) != "" && indent(i) <= blockIndent {
+					break
+				}
+				i = min(end+1, len(s))
+			}
+			if i > from {
+				blocks = append(blocks, [2]int{from, i})
+			}
+			lineStart, blockIndent = i, -1
+		case c == '#' && (i == lineStart || s[i-1] == ' ' || s[i-1] == '\t'):
+			j := lineEnd(s, i)
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case (c == '"' || c == '\'') && scalarStart(i):
+			if c == '"' {
+				i = quotedEnd(s, i, true, true)
+				continue
+			}
+			// Single quotes are escaped by doubling them.
+			j := i + 1
+			for j < len(s) && (s[j] != '\'' || j+1 < len(s) && s[j+1] == '\'') {
+				if s[j] == '\'' {
+					j++
+				}
+				j++
+			}
+			i = min(j+1, len(s))
+		case (c == '|' || c == '>') && scalarStart(i):
+			j := i + 1
+			for j < len(s) && strings.IndexByte("0123456789+-", s[j]) >= 0 {
+				j++
+			}
+			for j < len(s) && (s[j] == ' ' || s[j] == '\t') {
+				j++
+			}
+			if j == len(s) || s[j] == '\n' || s[j] == '#' {
+				blockIndent = indent(lineStart)
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return spans, blocks
+}
+
+// sqlCommentSpans finds the "--" and "/* */" comments of SQL, skipping
+// string literals, quoted identifiers and dollar-quoted strings.
+func sqlCommentSpans(s string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '-' && strings.HasPrefix(s[i:], "--"):
+			j := lineEnd(s, i)
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case c == '/' && strings.HasPrefix(s[i:], "/*"):
+			j := delimitedEnd(s, i+2, "*/")
+			spans = append(spans, [2]int{i, j})
+			i = j
+		case c == '\'' || c == '"' || c == '`':
+			// Quotes are escaped by doubling them, which scans as two
+			// adjacent literals.
+			i = quotedEnd(s, i, false, true)
+		case c == '$':
+			// $$...$$ or $tag$...$tag$
+			j := i + 1
+			for j < len(s) && s[j] != '$' && isCIdentByte(s[j]) && (j > i+1 || s[j] < '0' || s[j] > '9') {
+				j++
+			}
+			if j < len(s) && s[j] == '$' {
+				i = delimitedEnd(s, j+1, s[i:j+1])
+				continue
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+// markupCommentSpans finds the "<!-- -->" comments of HTML, XML, Vue and
+// Svelte, skipping CDATA sections, quoted attribute values and template
+// expressions ("{{ }}" in Vue, "{ }" in Svelte). The raw text of <script>
+// and <style> elements goes to the JavaScript and CSS lexers instead. An
+// unterminated "<!--" is left alone.
+func markupCommentSpans(s, lang string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], "<![CDATA["):
+			i = delimitedEnd(s, i+9, "]]>")
+		case strings.HasPrefix(s[i:], "<!--"):
+			j := strings.Index(s[i+4:], "-->")
+			if j < 0 {
+				return spans
+			}
+			spans = append(spans, [2]int{i, i + 4 + j + 3})
+			i += 4 + j + 3
+		case s[i] == '<' && i+1 < len(s) && isMarkupLetter(s[i+1]):
+			name, end := markupTag(s, i, lang)
+			lexer := markupRawText(name, s[i:end])
+			i = end
+			if lexer == "" || strings.HasSuffix(s[:end], "/>") {
+				continue
+			}
+			close := strings.Index(strings.ToLower(s[i:]), "</"+name)
+			if close < 0 {
+				close = len(s) - i
+			}
+			if lexer != "text" {
+				for _, sp := range commentSpans(s[i:i+close], lexer) {
+					spans = append(spans, [2]int{i + sp[0], i + sp[1]})
+				}
+			}
+			i += close
+		case lang == "vue" && strings.HasPrefix(s[i:], "{{"):
+			i = delimitedEnd(s, i+2, "}}")
+		case lang == "svelte" && s[i] == '{':
+			i = markupExprEnd(s, i)
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+// markupTag returns the lowercased name of the start tag at i and the
+// offset just past its '>', skipping quoted attribute values and, in
+// Svelte, attribute expressions.
+func markupTag(s string, i int, lang string) (string, int) {
+	j := i + 1
+	for j < len(s) && isMarkupNameByte(s[j]) {
+		j++
+	}
+	name := strings.ToLower(s[i+1 : j])
+	for j < len(s) && s[j] != '>' {
+		switch {
+		case s[j] == '"' || s[j] == '\'':
+			j = quotedEnd(s, j, false, true)
+		case lang == "svelte" && s[j] == '{':
+			j = markupExprEnd(s, j)
+		default:
+			j++
+		}
+	}
+	return name, min(j+1, len(s))
+}
+
+// markupRawText returns the language whose lexer finds the comments in the
+// raw text of an element named name with start tag tag: "javascript" for
+// scripts, "css" or the preprocessor named by lang for styles, and "text"
+// for scripts of other types, such as JSON or templates, and for the
+// escapable raw text of <textarea> and <title>, whose comments are not
+// looked for. It returns "" for elements whose content is markup.
+func markupRawText(name, tag string) string {
+	attrs := map[string]string{}
+	for _, m := range markupAttrRe.FindAllStringSubmatch(tag, -1) {
+		attrs[strings.ToLower(m[1])] = strings.ToLower(m[2] + m[3])
+	}
+	switch name {
+	case "style":
+		switch lang := attrs["lang"]; lang {
+		case "scss", "sass", "less":
+			return lang
+		}
+		return "css"
+	case "script":
+		switch typ := attrs["type"]; {
+		case typ == "", typ == "module", strings.Contains(typ, "javascript"), strings.Contains(typ, "ecmascript"), strings.Contains(typ, "typescript"):
+			return "javascript"
+		}
+		return "text"
+	case "textarea", "title":
+		return "text"
+	}
+	return ""
+}
+
+// markupAttrRe matches the type and lang attributes of a start tag.
+var markupAttrRe = regexp.MustCompile(`(?i)\s(type|lang)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// markupExprEnd returns the offset just past the Svelte expression whose
+// '{' is at i, skipping the braces and quotes inside it.
+func markupExprEnd(s string, i int) int {
+	depth := 0
+	for j := i; j < len(s); j++ {
+		switch s[j] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return j + 1
+			}
+		case '"', '\'', '`':
+			j = quotedEnd(s, j, true, true) - 1
+		}
+	}
+	return len(s)
+}
+
+func isMarkupLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isMarkupNameByte(c byte) bool {
+	return isWordByte(c) || c == '-' || c == ':' || c == '.'
+}
@@ -0,0 +1,64 @@
+package main
+
+import "testing"
+
+func TestCommentSpans(t *testing.T) {
+	tests := []struct {
+		name, lang, src, want string
+	}{
+		// Strings.
+		{"go string", "go", "s := \"http://x\" // c\n", "s := \"http://x\"\n"},
+		{"js string", "javascript", "let s = '/* no */'; // c\n", "let s = '/* no */';\n"},
+		{"c char", "c", "char c = '\"'; /* c */ int i;\n", "char c = '\"'; int i;\n"},
+		{"python string", "python", "s = \"# no\"  # c\n", "s = \"# no\"\n"},
+		{"yaml quoted", "yaml", "a: \"x # y\" # c\nb: 'it''s # z'\n", "a: \"x # y\"\nb: 'it''s # z'\n"},
+		{"sql string", "sql", "SELECT '--x' -- c\n", "SELECT '--x'\n"},
+
+		// Raw strings.
+		{"go raw", "go", "s := `a // b`\n", "s := `a // b`\n"},
+		{"rust raw", "rust", "let s = r#\"a \"// b\"#; // c\n", "let s = r#\"a \"// b\"#;\n"},
+		{"cpp raw", "cpp", "auto s = R\"x(/* a */)x\"; // c\n", "auto s = R\"x(/* a */)x\";\n"},
+		{"java text block", "java", "String s = \"\"\"\n// a\n\"\"\"; // c\n", "String s = \"\"\"\n// a\n\"\"\";\n"},
+		{"csharp verbatim", "csharp", "var s = @\"a\"\"//b\"; // c\n", "var s = @\"a\"\"//b\";\n"},
+		{"python triple", "python", "s = '''\n# a\n'''  # c\n", "s = '''\n# a\n'''\n"},
+		{"js template", "javascript", "let s = `// ${a /* b */}`; // c\n", "let s = `// ${a}`;\n"},
+
+		// Heredocs.
+		{"shell heredoc", "shell", "cat <<EOF # c\n# a\nEOF\necho # d\n", "cat <<EOF\n# a\nEOF\necho\n"},
+		{"shell heredoc tabs", "shell", "cat <<-'EOF'\n\t# a\n\tEOF\n# c\n", "cat <<-'EOF'\n\t# a\n\tEOF\n\n"},
+		{"shell shift", "shell", "echo $((1<<2)) # c\n# d\n", "echo $((1<<2))\n\n"},
+
+		// Regex literals.
+		{"js regex", "javascript", "let r = /\\/\\/x/; // c\n", "let r = /\\/\\/x/;\n"},
+		{"js regex class", "javascript", "let r = /[/]*/g; // c\n", "let r = /[/]*/g;\n"},
+		{"js division", "javascript", "let x = a / b; // c\n", "let x = a / b;\n"},
+
+		// Markup.
+		{"html comment", "html", "<p>a</p> <!-- c -->\n", "<p>a</p>\n"},
+		{"html attribute", "html", "<a title=\"x <!-- y -->\">z</a>\n", "<a title=\"x <!-- y -->\">z</a>\n"},
+		{"html script string", "html", "<script>var x = \"<!-- not -->\";</script>\n", "<script>var x = \"<!-- not -->\";</script>\n"},
+		{"html script comment", "html", "<script>\nf(); // c\n</script>\n", "<script>\nf();\n</script>\n"},
+		{"html style string", "html", "<style>a::after{content:\"<!--\"}</style>\n<p>b</p>\n", "<style>a::after{content:\"<!--\"}</style>\n<p>b</p>\n"},
+		{"html style comment", "html", "<style>a{} /* c */</style>\n", "<style>a{}</style>\n"},
+		{"html json script", "html", "<script type=\"application/json\">{\"a\": \"//b\"}</script>\n", "<script type=\"application/json\">{\"a\": \"//b\"}</script>\n"},
+		{"html unterminated", "html", "<p>a</p> <!-- c\n<p>b</p>\n", "<p>a</p> <!-- c\n<p>b</p>\n"},
+		{"xml cdata", "xml", "<a><![CDATA[<!-- x -->]]></a><!-- c -->\n", "<a><![CDATA[<!-- x -->]]></a>\n"},
+		{"vue expression", "vue", "<p>{{ \"<!-- not -->\" }}</p>\n", "<p>{{ \"<!-- not -->\" }}</p>\n"},
+		{"vue script", "vue", "<script lang=\"ts\">\nlet a = 1 /* c */\n// d\n</script>\n", "<script lang=\"ts\">\nlet a = 1\n\n</script>\n"},
+		{"vue scss", "vue", "<style lang=\"scss\">\n$a: 1; // c\n</style>\n", "<style lang=\"scss\">\n$a: 1;\n</style>\n"},
+		{"svelte expression", "svelte", "<p title={\"<!-- a -->\"}>{\"<!-- b -->\"}</p><!-- c -->\n", "<p title={\"<!-- a -->\"}>{\"<!-- b -->\"}</p>\n"},
+
+		// Whitespace around removed comments.
+		{"inline block", "c", "f(a, /* x */ b);\n", "f(a, b);\n"},
+		{"leading block", "c", "  /* x */ f();\n", "  f();\n"},
+		{"trailing line", "go", "x := 1 \t// c\n", "x := 1\n"},
+		{"trailing block", "c", "f(a /* x */);\n", "f(a);\n"},
+		{"joined words", "c", "int /* x */b;\n", "int b;\n"},
+		{"multi-line block", "c", "a /* x\ny */ b\n", "a \nb\n"},
+	}
+	for _, tt := range tests {
+		if got := removeSpans(tt.src, commentSpans(tt.src, tt.lang)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
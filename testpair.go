@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// testFuncPrefixes are the name prefixes of functions run by go test.
+var testFuncPrefixes = []string{"Test", "Benchmark", "Example", "Fuzz"}
+
+// pairTests adds the Go tests that exercise the focused functions: the test
+// functions in the directories of roots, the symbols the focus symbol
+// resolved to, that call one of them by name. Test files already in the context get their weight raised;
+// test files that were excluded are added with only the matching functions
+// (plus package clause and imports) so that the bundle stays small. It
+// returns the paths of the added files.
+//
+// Candidates go through the same filters as the walk. Patterns excluding
+// test files as such are what pairing overrides, so a test file is only
+// left out by patterns that also exclude the sources it tests.
+func pairTests(ctx *Context, cfg *Config, roots []symbolNode) []string {
+	names := map[string]bool{}
+	dirs := map[string]bool{}
+	for _, n := range roots {
+		if !strings.HasSuffix(n.Path, ".go") {
+			continue
+		}
+		names[symbolBase(n.Key)] = true
+		dirs[filepath.Dir(n.Path)] = true
+	}
+
+	existing := map[string]int{}
+	for i, f := range ctx.Files {
+		existing[f.Path] = i
+	}
+
+	var candidates []string
+	for dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(cfg.Path, dir, "*_test.go"))
+		candidates = append(candidates, matches...)
+	}
+	sort.Strings(candidates)
+
+	var added []string
+	for _, path := range candidates {
+		relPath, _ := filepath.Rel(cfg.Path, path)
+		source := strings.TrimSuffix(relPath, "_test.go") + ".go"
+		if shouldExclude(source, cfg.Exclude, cfg.Include) || filteredOut(path, relPath, cfg) {
+			continue
+		}
+		ranges := testRanges(path, names)
+		if len(ranges) == 0 {
+			continue
+		}
+		if i, ok := existing[relPath]; ok {
+			if ctx.Files[i].Weight > 0 {
+				ctx.Files[i].Weight += cfg.TestWeight
+			}
+			continue
+		}
+		fi, err := processFile(path, cfg, ranges)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to process %s: %v\n", path, err)
+			continue
+		}
+		// Tests down-weighted as generated or for another platform stay so.
+		if fi.Weight > 0 {
+			fi.Weight = cfg.TestWeight
+		}
+		if cfg.Stream {
+			// Contents are re-read when the output is written.
+			fi.Content = ""
+		}
+		ctx.Files = append(ctx.Files, *fi)
+		ctx.TotalSize += fi.bundledSize()
+		added = append(added, relPath)
+	}
+	sort.Slice(ctx.Files, func(i, j int) bool { return ctx.Files[i].Path < ctx.Files[j].Path })
+	ctx.TotalFiles = len(ctx.Files)
+	return added
+}
+
+// testRanges returns the line ranges of the package clause, imports and the
+// test functions calling one of names in a Go test file, or nil if there are
+// none.
+func testRanges(path string, names map[string]bool) []LineRange {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, src, parser.ParseComments)
+	if err != nil {
+		return nil
+	}
+	span := func(from, to token.Pos) LineRange {
+		return LineRange{Start: fset.Position(from).Line, End: fset.Position(to).Line}
+	}
+
+	var funcs []LineRange
+	for _, decl := range f.Decls {
+		fd, ok := decl.(*ast.FuncDecl)
+		if !ok || fd.Recv != nil || !isTestFunc(fd.Name.Name) || !callsAny(fd, names) {
+			continue
+		}
+		start := fd.Pos()
+		if fd.Doc != nil {
+			start = fd.Doc.Pos()
+		}
+		funcs = append(funcs, span(start, fd.End()))
+	}
+	if len(funcs) == 0 {
+		return nil
+	}
+
+	ranges := []LineRange{span(f.Package, f.Name.End())}
+	for _, decl := range f.Decls {
+		if gd, ok := decl.(*ast.GenDecl); ok && gd.Tok == token.IMPORT {
+			ranges = append(ranges, span(gd.Pos(), gd.End()))
+		}
+	}
+	return append(ranges, funcs...)
+}
+
+// isTestFunc reports whether name is a test, benchmark, example or fuzz function.
+func isTestFunc(name string) bool {
+	for _, prefix := range testFuncPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// callsAny reports whether the body of fd calls a function or method named
+// in names, matched by its unqualified name.
+func callsAny(fd *ast.FuncDecl, names map[string]bool) bool {
+	found := false
+	ast.Inspect(fd, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || found {
+			return !found
+		}
+		switch fun := call.Fun.(type) {
+		case *ast.Ident:
+			found = names[fun.Name]
+		case *ast.SelectorExpr:
+			found = names[fun.Sel.Name]
+		}
+		return !found
+	})
+	return found
+}